@@ -1,8 +1,12 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -124,13 +128,14 @@ func (a *BaseApp) Init(serviceName string) {
 	a.Connections.Redis = redisClient
 
 	// Add CORS middleware
-	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true
-	config.AllowHeaders = []string{"*"}
-	a.Router.Use(cors.New(config))
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowAllOrigins = true
+	corsConfig.AllowHeaders = []string{"*"}
+	a.Router.Use(cors.New(corsConfig))
 
-	// Register connections in the container
+	// Register connections and configuration in the container
 	a.Container.Register((*utils.Connections)(nil), &a.Connections)
+	a.Container.Register((*config.AppConfig)(nil), a.Config)
 
 	// Register all the http clients in the container
 	a.registerHttpClients()
@@ -176,7 +181,8 @@ func (a *BaseApp) Bootstrap() error {
 	return nil
 }
 
-// StartHTTPHandler starts the HTTP server
+// StartHTTPHandler starts the HTTP server and blocks until the process is asked to stop with
+// SIGINT or SIGTERM, then shuts the application down gracefully
 func (a *BaseApp) StartHTTPHandler(port string) {
 	const functionName = "main.App.StartHTTPHandler"
 
@@ -190,11 +196,61 @@ func (a *BaseApp) StartHTTPHandler(port string) {
 	// Add health check endpoint
 	a.Router.GET("/health", a.healthCheck)
 
-	logger.Info(functionName, "starting_http_server", "port", port)
-	err := http.ListenAndServe(":"+port, a.Router)
-	if err != nil {
-		logger.Error(functionName, err)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	server := &http.Server{Addr: ":" + port, Handler: a.Router}
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info(functionName, "starting_http_server", "port", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case sig := <-signals:
+		logger.Info(functionName, "shutdown_signal_received", sig.String())
+	case err := <-serverErr:
+		logger.Error(functionName, "http server failed:", err)
 	}
+
+	a.shutdown(server)
+}
+
+// shutdown stops accepting HTTP requests, lets the modules stop their background work and
+// finish in-flight sends, then closes the MySQL and Redis connections. The HTTP drain has
+// its own HTTPShutdownTimeout deadline so slow requests cannot eat into the ShutdownTimeout
+// the modules get for their sends.
+func (a *BaseApp) shutdown(server *http.Server) {
+	const functionName = "main.App.shutdown"
+	logger.Info(functionName, "shutting_down", "http_timeout", a.Config.HTTPShutdownTimeout.String(), "timeout", a.Config.ShutdownTimeout.String())
+
+	httpCtx, httpCancel := context.WithTimeout(context.Background(), a.Config.HTTPShutdownTimeout)
+	defer httpCancel()
+
+	if err := server.Shutdown(httpCtx); err != nil {
+		logger.Error(functionName, "failed to shut down http server:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.Config.ShutdownTimeout)
+	defer cancel()
+
+	for _, module := range a.Modules {
+		if err := module.Shutdown(ctx, a.Container); err != nil {
+			logger.Error(functionName, "failed to shut down module:", module.Name(), err)
+		}
+	}
+
+	if err := a.Connections.Redis.Close(); err != nil {
+		logger.Error(functionName, "failed to close Redis connection:", err)
+	}
+	if err := a.Connections.DB.Close(); err != nil {
+		logger.Error(functionName, "failed to close MySQL connection:", err)
+	}
+
+	logger.Info(functionName, "shutdown_complete")
 }
 
 // healthCheck provides a health endpoint
@@ -221,5 +277,14 @@ func (a *BaseApp) healthCheck(c *gin.Context) {
 		logger.Error(functionName, "Redis health check failed:", err)
 	}
 
+	// Module specific entries such as the poller state
+	for _, module := range a.Modules {
+		if reporter, ok := module.(HealthReporter); ok {
+			for key, value := range reporter.Health(a.Container) {
+				health[key] = value
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, health)
 }
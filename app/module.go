@@ -1,6 +1,8 @@
 package app
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
 
 	"github.com/smitendu1997/auto-message-dispatcher/di"
@@ -16,4 +18,12 @@ type Module interface {
 
 	// RegisterRoutes sets up this module's routes
 	RegisterRoutes(router *gin.Engine, container *di.Container)
+
+	// Shutdown stops this module's background work, giving up once ctx is done
+	Shutdown(ctx context.Context, container *di.Container) error
+}
+
+// HealthReporter is implemented by modules that add their own entries to the health check
+type HealthReporter interface {
+	Health(container *di.Container) map[string]string
 }
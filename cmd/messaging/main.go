@@ -25,9 +25,9 @@ func main() {
 	// Register modules based on configuration
 	registerModules(application, appConfig)
 
-	// Start HTTP handler
+	// Start HTTP handler, returns once the app has shut down after SIGINT or SIGTERM
 	application.StartHTTPHandler(viper.GetString("MESSAGING_API_PORT"))
-	logger.Info(functionName, "app_started")
+	logger.Info(functionName, "app_stopped")
 }
 
 func registerModules(app app.App, appConfig *config.AppConfig) {
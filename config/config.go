@@ -6,8 +6,10 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/smitendu1997/auto-message-dispatcher/logger"
+	"github.com/smitendu1997/auto-message-dispatcher/utils"
 	"github.com/spf13/viper"
 )
 
@@ -18,8 +20,17 @@ type AppConfig struct {
 	MessagingApiKey string
 	Database        DBConfig
 	Redis           RedisConfig
+	Messaging       MessagingConfig
+	// HTTPShutdownTimeout bounds how long a graceful shutdown waits for in-flight HTTP requests
+	HTTPShutdownTimeout time.Duration
+	// ShutdownTimeout bounds how long a graceful shutdown waits for in-flight sends, it is raised to
+	// at least the send timeout plus shutdownHeadroom so a send that started just before is not cut off
+	ShutdownTimeout time.Duration
 }
 
+// shutdownHeadroom is the time left after the slowest send for recording its outcome
+const shutdownHeadroom = 10 * time.Second
+
 // DBConfig holds database configuration
 type DBConfig struct {
 	Type     string
@@ -42,6 +53,56 @@ type RedisConfig struct {
 	URL      string
 }
 
+// MessagingConfig holds messaging API and dispatch configuration
+type MessagingConfig struct {
+	BulkMaxBatchSize  int
+	DispatchBatchSize int
+	DispatchWorkers   int
+	// DispatchRateLimit is the most messages sent per second by all replicas together, 0 is unlimited
+	DispatchRateLimit float64
+	// DispatchRateLimitBurst is how many sends may go out back to back, 0 is one second's worth,
+	// anything else must be at least 1
+	DispatchRateLimitBurst float64
+	// DispatchRateLimitFallback is this replica's limit while Redis is down, 0 is the rate limit
+	// divided by DispatchReplicas
+	DispatchRateLimitFallback float64
+	// DispatchReplicas is how many replicas share the rate limit
+	DispatchReplicas int
+	// SendTimeout bounds a single call to the messaging gateway
+	SendTimeout time.Duration
+	// FrequencyCaps limits messages per recipient, e.g. "3/1h,10/24h", empty disables capping
+	FrequencyCaps string
+	// FrequencyCapAction is defer or reject, what happens to a message that would exceed a cap
+	FrequencyCapAction string
+	// InstanceID identifies this replica when it claims messages, defaults to hostname-pid
+	InstanceID string
+	// LeaseDuration is how long a claimed message stays reserved for this replica
+	LeaseDuration time.Duration
+	Retry         RetryConfig
+	Poll          PollConfig
+}
+
+// PollConfig holds the poller schedule
+type PollConfig struct {
+	Interval     time.Duration
+	Adaptive     bool
+	BusyInterval time.Duration
+	// OnCreate wakes the poller when a message that is already due is created
+	OnCreate bool
+	// LeaderElection lets only the replica holding a Redis lock poll, the lock expires after LeaderLockTTL without renewal
+	LeaderElection bool
+	LeaderLockTTL  time.Duration
+}
+
+// RetryConfig holds the exponential backoff policy for failed messages
+type RetryConfig struct {
+	BaseDelay   time.Duration
+	Multiplier  float64
+	Jitter      float64
+	MaxDelay    time.Duration
+	MaxAttempts int
+}
+
 // LoadConfig initializes the configuration for the service.
 func LoadConfig() *AppConfig {
 	// If env is empty, use environment variable or default
@@ -78,6 +139,31 @@ func LoadConfig() *AppConfig {
 	// Set defaults
 	viper.SetDefault("DATABASE_TYPE", "mysql")
 	viper.SetDefault("REDIS_TYPE", "redis")
+	viper.SetDefault("HTTP_SHUTDOWN_TIMEOUT", "10s")
+	viper.SetDefault("SHUTDOWN_TIMEOUT", "45s")
+	viper.SetDefault("MESSAGING_BULK_MAX_BATCH_SIZE", 1000)
+	viper.SetDefault("MESSAGING_DISPATCH_BATCH_SIZE", 100)
+	viper.SetDefault("MESSAGING_DISPATCH_WORKERS", 10)
+	viper.SetDefault("MESSAGING_DISPATCH_RATE_LIMIT", 0)
+	viper.SetDefault("MESSAGING_DISPATCH_RATE_LIMIT_BURST", 0)
+	viper.SetDefault("MESSAGING_DISPATCH_RATE_LIMIT_FALLBACK", 0)
+	viper.SetDefault("MESSAGING_DISPATCH_REPLICAS", 1)
+	viper.SetDefault("MESSAGING_SEND_TIMEOUT", "30s")
+	viper.SetDefault("MESSAGING_FREQUENCY_CAPS", "")
+	viper.SetDefault("MESSAGING_FREQUENCY_CAP_ACTION", "defer")
+	viper.SetDefault("MESSAGING_INSTANCE_ID", utils.DefaultInstanceID())
+	viper.SetDefault("MESSAGING_DISPATCH_LEASE_DURATION", "6m")
+	viper.SetDefault("MESSAGING_RETRY_BASE_DELAY", "30s")
+	viper.SetDefault("MESSAGING_RETRY_MULTIPLIER", 2)
+	viper.SetDefault("MESSAGING_RETRY_JITTER", 0.2)
+	viper.SetDefault("MESSAGING_RETRY_MAX_DELAY", "1h")
+	viper.SetDefault("MESSAGING_RETRY_MAX_ATTEMPTS", 5)
+	viper.SetDefault("MESSAGING_POLL_INTERVAL", "120s")
+	viper.SetDefault("MESSAGING_POLL_ADAPTIVE", false)
+	viper.SetDefault("MESSAGING_POLL_BUSY_INTERVAL", "0s")
+	viper.SetDefault("MESSAGING_POLL_ON_CREATE", false)
+	viper.SetDefault("MESSAGING_LEADER_ELECTION", false)
+	viper.SetDefault("MESSAGING_LEADER_LOCK_TTL", "30s")
 
 	if err := viper.ReadInConfig(); err != nil {
 		// Config file not found, use environment variables only
@@ -103,8 +189,47 @@ func LoadConfig() *AppConfig {
 			Password: viper.GetString("REDIS_PASSWORD"),
 			Db:       viper.GetString("REDIS_DB"),
 		},
-		MessagingApiUrl: viper.GetString("MESSAGING_API_BASE_URL"),
-		MessagingApiKey: viper.GetString("MESSAGING_API_KEY"),
+		Messaging: MessagingConfig{
+			BulkMaxBatchSize:          viper.GetInt("MESSAGING_BULK_MAX_BATCH_SIZE"),
+			DispatchBatchSize:         viper.GetInt("MESSAGING_DISPATCH_BATCH_SIZE"),
+			DispatchWorkers:           viper.GetInt("MESSAGING_DISPATCH_WORKERS"),
+			DispatchRateLimit:         viper.GetFloat64("MESSAGING_DISPATCH_RATE_LIMIT"),
+			DispatchRateLimitBurst:    viper.GetFloat64("MESSAGING_DISPATCH_RATE_LIMIT_BURST"),
+			DispatchRateLimitFallback: viper.GetFloat64("MESSAGING_DISPATCH_RATE_LIMIT_FALLBACK"),
+			DispatchReplicas:          viper.GetInt("MESSAGING_DISPATCH_REPLICAS"),
+			SendTimeout:               viper.GetDuration("MESSAGING_SEND_TIMEOUT"),
+			FrequencyCaps:             viper.GetString("MESSAGING_FREQUENCY_CAPS"),
+			FrequencyCapAction:        viper.GetString("MESSAGING_FREQUENCY_CAP_ACTION"),
+			InstanceID:                viper.GetString("MESSAGING_INSTANCE_ID"),
+			LeaseDuration:             viper.GetDuration("MESSAGING_DISPATCH_LEASE_DURATION"),
+			Retry: RetryConfig{
+				BaseDelay:   viper.GetDuration("MESSAGING_RETRY_BASE_DELAY"),
+				Multiplier:  viper.GetFloat64("MESSAGING_RETRY_MULTIPLIER"),
+				Jitter:      viper.GetFloat64("MESSAGING_RETRY_JITTER"),
+				MaxDelay:    viper.GetDuration("MESSAGING_RETRY_MAX_DELAY"),
+				MaxAttempts: viper.GetInt("MESSAGING_RETRY_MAX_ATTEMPTS"),
+			},
+			Poll: PollConfig{
+				Interval:       viper.GetDuration("MESSAGING_POLL_INTERVAL"),
+				Adaptive:       viper.GetBool("MESSAGING_POLL_ADAPTIVE"),
+				BusyInterval:   viper.GetDuration("MESSAGING_POLL_BUSY_INTERVAL"),
+				OnCreate:       viper.GetBool("MESSAGING_POLL_ON_CREATE"),
+				LeaderElection: viper.GetBool("MESSAGING_LEADER_ELECTION"),
+				LeaderLockTTL:  viper.GetDuration("MESSAGING_LEADER_LOCK_TTL"),
+			},
+		},
+		MessagingApiUrl:     viper.GetString("MESSAGING_API_BASE_URL"),
+		MessagingApiKey:     viper.GetString("MESSAGING_API_KEY"),
+		HTTPShutdownTimeout: viper.GetDuration("HTTP_SHUTDOWN_TIMEOUT"),
+		ShutdownTimeout:     viper.GetDuration("SHUTDOWN_TIMEOUT"),
+	}
+
+	// The connections are closed once the shutdown deadline passes, a send still running then could
+	// not record its outcome
+	if minimum := config.Messaging.SendTimeout + shutdownHeadroom; config.ShutdownTimeout < minimum {
+		logger.Info("config.LoadConfig", "shutdown_timeout_raised", "configured", config.ShutdownTimeout.String(),
+			"send_timeout", config.Messaging.SendTimeout.String(), "effective", minimum.String())
+		config.ShutdownTimeout = minimum
 	}
 
 	// Build connection strings
@@ -4,16 +4,38 @@ package domain
 type MessageStatus string
 
 const (
-	MessageStatusPending MessageStatus = "pending"
-	MessageStatusSent    MessageStatus = "sent"
-	MessageStatusFailed  MessageStatus = "failed"
+	MessageStatusPending   MessageStatus = "pending"
+	MessageStatusSent      MessageStatus = "sent"
+	MessageStatusFailed    MessageStatus = "failed"
+	MessageStatusExpired   MessageStatus = "expired"
+	MessageStatusCancelled MessageStatus = "cancelled"
+	// MessageStatusProcessing marks a message claimed by a dispatcher instance that is being sent
+	MessageStatusProcessing MessageStatus = "processing"
+	// MessageStatusDead marks a message that used up its retry attempts, it is only sent again when requeued
+	MessageStatusDead MessageStatus = "dead"
+	// MessageStatusCapped marks a message rejected because its recipient reached a frequency cap
+	MessageStatusCapped MessageStatus = "capped"
 )
 
 // IsValid checks if the value is a valid MessageStatus
 func (s MessageStatus) IsValid() bool {
 	switch s {
-	case MessageStatusPending, MessageStatusSent, MessageStatusFailed:
+	case MessageStatusPending, MessageStatusSent, MessageStatusFailed, MessageStatusExpired, MessageStatusCancelled, MessageStatusProcessing, MessageStatusDead, MessageStatusCapped:
 		return true
 	}
 	return false
 }
+
+// IsCancellable checks if a message in this status has not been dispatched yet and can be cancelled
+func (s MessageStatus) IsCancellable() bool {
+	switch s {
+	case MessageStatusPending, MessageStatusFailed:
+		return true
+	}
+	return false
+}
+
+// IsRequeueable checks if a message in this status may be reset and sent again
+func (s MessageStatus) IsRequeueable() bool {
+	return s == MessageStatusDead
+}
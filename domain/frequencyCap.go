@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FrequencyCapAction is what happens to a message that would exceed a frequency cap
+type FrequencyCapAction string
+
+const (
+	// FrequencyCapActionDefer puts the message back in the queue until the cap allows it
+	FrequencyCapActionDefer FrequencyCapAction = "defer"
+	// FrequencyCapActionReject gives up on the message with the capped status
+	FrequencyCapActionReject FrequencyCapAction = "reject"
+)
+
+// IsValid checks if the value is a valid FrequencyCapAction
+func (a FrequencyCapAction) IsValid() bool {
+	return a == FrequencyCapActionDefer || a == FrequencyCapActionReject
+}
+
+// FrequencyCapRule allows at most Limit messages to the same recipient within any Window
+type FrequencyCapRule struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (r FrequencyCapRule) String() string {
+	return fmt.Sprintf("%d per %s", r.Limit, r.Window)
+}
+
+var ErrInvalidFrequencyCapRule = errors.New("frequency cap rules must look like 3/1h,10/24h")
+
+// ParseFrequencyCapRules parses a comma separated list of limit/window rules such as "3/1h,10/24h".
+// An empty spec means no caps.
+func ParseFrequencyCapRules(spec string) ([]FrequencyCapRule, error) {
+	var rules []FrequencyCapRule
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		limitStr, windowStr, ok := strings.Cut(part, "/")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidFrequencyCapRule, part)
+		}
+		limit, err := strconv.Atoi(strings.TrimSpace(limitStr))
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidFrequencyCapRule, part)
+		}
+		window, err := time.ParseDuration(strings.TrimSpace(windowStr))
+		if err != nil || window < time.Second {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidFrequencyCapRule, part)
+		}
+		rules = append(rules, FrequencyCapRule{Limit: limit, Window: window})
+	}
+	return rules, nil
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFrequencyCapRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    []FrequencyCapRule
+		wantErr bool
+	}{
+		{name: "empty spec has no caps", spec: "", want: nil},
+		{name: "single rule", spec: "3/1h", want: []FrequencyCapRule{{Limit: 3, Window: time.Hour}}},
+		{
+			name: "several rules with spaces",
+			spec: " 3 / 1h , 10/24h ,",
+			want: []FrequencyCapRule{{Limit: 3, Window: time.Hour}, {Limit: 10, Window: 24 * time.Hour}},
+		},
+		{name: "missing window", spec: "3", wantErr: true},
+		{name: "limit not a number", spec: "x/1h", wantErr: true},
+		{name: "zero limit", spec: "0/1h", wantErr: true},
+		{name: "window not a duration", spec: "3/day", wantErr: true},
+		{name: "window below a second", spec: "3/500ms", wantErr: true},
+		{name: "one bad rule fails the spec", spec: "3/1h,10", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFrequencyCapRules(tt.spec)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidFrequencyCapRule) {
+					t.Fatalf("ParseFrequencyCapRules(%q) error = %v, want ErrInvalidFrequencyCapRule", tt.spec, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFrequencyCapRules(%q) error = %v", tt.spec, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseFrequencyCapRules(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+// GatewayErrorKind classifies why the messaging service did not take a message
+type GatewayErrorKind string
+
+const (
+	// GatewayErrorNetwork covers connection failures and timeouts, the request may not have reached the provider
+	GatewayErrorNetwork GatewayErrorKind = "network"
+	// GatewayErrorThrottled is returned when the provider rate limits us (HTTP 429)
+	GatewayErrorThrottled GatewayErrorKind = "throttled"
+	// GatewayErrorServer is a provider side failure (HTTP 5xx)
+	GatewayErrorServer GatewayErrorKind = "server_error"
+	// GatewayErrorRejected means the provider refused the message itself, e.g. an invalid recipient (HTTP 4xx)
+	GatewayErrorRejected GatewayErrorKind = "rejected"
+	// GatewayErrorMalformedResponse is an unexpected status that is neither a success nor an error, e.g. a redirect
+	GatewayErrorMalformedResponse GatewayErrorKind = "malformed_response"
+	// GatewayErrorTimeout means the send deadline passed or the send was cancelled before the provider answered.
+	// The provider may still have accepted the message, the retry carries the same idempotency key.
+	GatewayErrorTimeout GatewayErrorKind = "timeout"
+)
+
+// maxGatewayErrorBodyLength bounds how much of the provider body is repeated in the error message
+const maxGatewayErrorBodyLength = 200
+
+// GatewayError is returned by the messaging gateway when a message could not be sent
+type GatewayError struct {
+	Kind       GatewayErrorKind
+	StatusCode int
+	// Body is the raw provider response, if any
+	Body string
+	Err  error
+}
+
+func (e *GatewayError) Error() string {
+	msg := "messaging service " + string(e.Kind)
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%s (status %d)", msg, e.StatusCode)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Body != "" {
+		body := e.Body
+		if utf8.RuneCountInString(body) > maxGatewayErrorBodyLength {
+			body = string([]rune(body)[:maxGatewayErrorBodyLength]) + "..."
+		}
+		msg += ": " + body
+	}
+	return msg
+}
+
+func (e *GatewayError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether sending the message again later may succeed
+func (e *GatewayError) IsRetryable() bool {
+	switch e.Kind {
+	case GatewayErrorNetwork, GatewayErrorTimeout, GatewayErrorThrottled, GatewayErrorServer:
+		return true
+	}
+	return false
+}
+
+// IsRetryableGatewayError reports whether a send error is worth retrying. Errors that are not
+// classified by the gateway are treated as retryable.
+func IsRetryableGatewayError(err error) bool {
+	var gatewayErr *GatewayError
+	if errors.As(err, &gatewayErr) {
+		return gatewayErr.IsRetryable()
+	}
+	return true
+}
+
+// IsGatewayTimeout reports whether a send was abandoned before the provider answered, the message
+// may have been delivered all the same
+func IsGatewayTimeout(err error) bool {
+	var gatewayErr *GatewayError
+	return errors.As(err, &gatewayErr) && gatewayErr.Kind == GatewayErrorTimeout
+}
+
+// NewGatewayStatusError classifies a non-accepted HTTP response from the messaging service
+func NewGatewayStatusError(statusCode int, body []byte) *GatewayError {
+	gatewayErr := &GatewayError{StatusCode: statusCode, Body: string(body)}
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		gatewayErr.Kind = GatewayErrorThrottled
+	case statusCode == http.StatusRequestTimeout:
+		gatewayErr.Kind = GatewayErrorNetwork
+	case statusCode >= http.StatusInternalServerError:
+		gatewayErr.Kind = GatewayErrorServer
+	case statusCode >= http.StatusBadRequest:
+		gatewayErr.Kind = GatewayErrorRejected
+	default:
+		gatewayErr.Kind = GatewayErrorMalformedResponse
+	}
+	return gatewayErr
+}
@@ -1,9 +1,62 @@
 package domain
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
 	"time"
+	"unicode/utf8"
 )
 
+const (
+	// MaxRecipientPhoneLength mirrors the VARCHAR(20) limit of messages.recipient_phone
+	MaxRecipientPhoneLength = 20
+	// MaxContentLength mirrors the VARCHAR(200) limit of messages.content
+	MaxContentLength = 200
+	// MaxClientReferenceLength mirrors the VARCHAR(100) limit of messages.client_reference
+	MaxClientReferenceLength = 100
+)
+
+var (
+	ErrRecipientPhoneRequired = errors.New("recipient_phone is required")
+	ErrRecipientPhoneTooLong  = errors.New("recipient_phone must not exceed 20 characters")
+	ErrRecipientPhoneInvalid  = errors.New("recipient_phone must contain only digits with an optional leading +")
+	ErrContentRequired        = errors.New("content is required")
+	ErrContentTooLong         = errors.New("content must not exceed 200 characters")
+	ErrClientReferenceTooLong = errors.New("client_reference must not exceed 100 characters")
+	ErrExpiresAtInPast        = errors.New("expires_at must be in the future")
+	ErrExpiresBeforeSchedule  = errors.New("expires_at must be after scheduled_at")
+
+	ErrMessageNotFound          = errors.New("message not found")
+	ErrMessageNotCancellable    = errors.New("message can no longer be cancelled")
+	ErrMessageNotRequeueable    = errors.New("only dead-lettered messages can be requeued")
+	ErrDuplicateClientReference = errors.New("client_reference already exists")
+	ErrClientReferenceReused    = errors.New("client_reference was already used for a different message")
+)
+
+// NotCancellableError explains why a message in the given status can no longer be cancelled, it wraps
+// ErrMessageNotCancellable
+func NotCancellableError(status MessageStatus) error {
+	var reason string
+	switch status {
+	case MessageStatusProcessing:
+		reason = "it is being sent right now"
+	case MessageStatusSent:
+		reason = "it was already sent"
+	case MessageStatusExpired:
+		reason = "it expired before it was sent"
+	case MessageStatusDead:
+		reason = "it used up its send attempts, requeue or leave it instead"
+	case MessageStatusCapped:
+		reason = "it was rejected by a recipient frequency cap"
+	default:
+		reason = fmt.Sprintf("it is %s", status)
+	}
+	return fmt.Errorf("%w: %s", ErrMessageNotCancellable, reason)
+}
+
+var recipientPhonePattern = regexp.MustCompile(`^\+?[0-9]+$`)
+
 type MessageDomain struct {
 	ID             int64
 	RecipientPhone string
@@ -12,8 +65,100 @@ type MessageDomain struct {
 	MessageID      *string
 	SentAt         *time.Time
 	RetryCount     int
+	// ClientReference is the client supplied idempotency key, if any
+	ClientReference *string
+	// ScheduledAt is the earliest time the message may be sent, nil sends on the next poll
+	ScheduledAt *time.Time
+	// ExpiresAt is the time after which the message must not be sent, nil never expires
+	ExpiresAt *time.Time
+	// NextAttemptAt is the earliest time a failed message is retried, nil retries on the next poll
+	NextAttemptAt *time.Time
+	// LastError is the reason the most recent send attempt failed
+	LastError *string
+	CreatedOn *time.Time
+	UpdatedOn *time.Time
+}
+
+// MessageFilter narrows a message search, nil fields are not filtered on
+type MessageFilter struct {
+	Status         *MessageStatus
+	RecipientPhone *string
+	// CreatedFrom and CreatedTo bound the creation time, CreatedTo is exclusive
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// Query matches messages whose content contains the text
+	Query *string
+}
+
+// MessageAttempt is a single call made to the messaging gateway for a message
+type MessageAttempt struct {
+	ID                int64
+	MessageID         int64
+	Status            MessageStatus
+	ProviderMessageID *string
+	Error             *string
+	Duration          time.Duration
+	AttemptedAt       time.Time
+}
+
+// CreatedMessage is the outcome of a create request, Replayed is true when the
+// client reference matched an existing message and nothing new was inserted.
+// Err is set instead when an entry of a batch could not be created.
+type CreatedMessage struct {
+	Message  *MessageDomain
+	Replayed bool
+	Err      error
 }
 
 func (m *MessageDomain) IsMessageSent() bool {
 	return m.Status == MessageStatusSent
 }
+
+// SamePayload reports whether other carries the same message, times are compared to the second
+// because the database does not keep fractions
+func (m *MessageDomain) SamePayload(other *MessageDomain) bool {
+	return m.RecipientPhone == other.RecipientPhone &&
+		m.Content == other.Content &&
+		sameSecond(m.ScheduledAt, other.ScheduledAt) &&
+		sameSecond(m.ExpiresAt, other.ExpiresAt)
+}
+
+func sameSecond(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Sub(*b).Abs() < time.Second
+}
+
+// IsExpired reports whether the message can no longer be sent at the given time
+func (m *MessageDomain) IsExpired(now time.Time) bool {
+	return m.ExpiresAt != nil && !now.Before(*m.ExpiresAt)
+}
+
+// IsDue reports whether the message may be sent at the given time
+func (m *MessageDomain) IsDue(now time.Time) bool {
+	return m.ScheduledAt == nil || !now.Before(*m.ScheduledAt)
+}
+
+// Validate checks that the message fits the constraints of the messages table
+func (m *MessageDomain) Validate() error {
+	switch {
+	case m.RecipientPhone == "":
+		return ErrRecipientPhoneRequired
+	case utf8.RuneCountInString(m.RecipientPhone) > MaxRecipientPhoneLength:
+		return ErrRecipientPhoneTooLong
+	case !recipientPhonePattern.MatchString(m.RecipientPhone):
+		return ErrRecipientPhoneInvalid
+	case m.Content == "":
+		return ErrContentRequired
+	case utf8.RuneCountInString(m.Content) > MaxContentLength:
+		return ErrContentTooLong
+	case m.ClientReference != nil && utf8.RuneCountInString(*m.ClientReference) > MaxClientReferenceLength:
+		return ErrClientReferenceTooLong
+	case m.IsExpired(time.Now()):
+		return ErrExpiresAtInPast
+	case m.ExpiresAt != nil && m.ScheduledAt != nil && !m.ExpiresAt.After(*m.ScheduledAt):
+		return ErrExpiresBeforeSchedule
+	}
+	return nil
+}
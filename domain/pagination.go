@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"time"
+)
+
+// PageRequest describes a page of a message listing. When Cursor is set the listing
+// continues after the cursor (keyset pagination) and Offset is ignored.
+type PageRequest struct {
+	Limit        int32
+	Offset       int32
+	Cursor       *MessageCursor
+	IncludeTotal bool
+}
+
+// MessageCursor identifies the last message of a page in the listing sort order.
+// SortTime holds the time column the listing is ordered by and is nil for listings
+// ordered by id only.
+type MessageCursor struct {
+	SortTime *time.Time
+	ID       int64
+}
+
+// MessagePage is a page of a message listing, Total is only set when it was requested
+type MessagePage struct {
+	Messages   []*MessageDomain
+	Total      *int64
+	HasMore    bool
+	NextCursor *MessageCursor
+}
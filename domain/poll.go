@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrBatchOutlastsLease is returned for dispatch settings under which the rate limit or the workers cannot
+// send a whole batch before the claims on it expire, the rest of the batch would then be recovered and sent twice
+var ErrBatchOutlastsLease = errors.New("batch_size cannot be sent before the claim lease expires at this rate_limit and worker count")
+
+// PollSummary reports what a single run of the dispatcher did
+type PollSummary struct {
+	StartedAt time.Time
+	// Duration is the wall time of the whole poll
+	Duration time.Duration
+	// BatchSize is the most messages the poll was allowed to claim
+	BatchSize int
+	// Claimed is the number of due messages picked up for sending
+	Claimed int
+	Sent    int
+	// Failed counts sends that will be retried or were dead-lettered after their last attempt
+	Failed int
+	// PermanentlyFailed counts sends the gateway refused for good, these are dead-lettered immediately
+	PermanentlyFailed int
+	Expired           int
+	AlreadySent       int
+	// Capped counts messages deferred or rejected because their recipient reached a frequency cap
+	Capped int
+	// Skipped counts claimed messages that were handed back unsent because the poll was interrupted or
+	// their lease would have run out during the send
+	Skipped int
+	// ExpiredBeforeClaim, RecoveredLeases and DeadLettered come from the housekeeping run before claiming
+	ExpiredBeforeClaim int64
+	RecoveredLeases    int64
+	DeadLettered       int64
+	// SendDuration is the total time spent waiting on the gateway, MaxSendDuration the slowest single send
+	SendDuration    time.Duration
+	MaxSendDuration time.Duration
+	// Err is set when the poll could not claim messages at all
+	Err error
+}
+
+// IsFullBatch reports whether the poll claimed as many messages as it could, meaning more are likely waiting.
+// A batch that mostly failed or was handed back unsent does not count, polling again straight away
+// would only keep hitting a provider or rate limiter that is failing.
+func (s PollSummary) IsFullBatch() bool {
+	failures := s.Failed + s.PermanentlyFailed + s.Skipped
+	return s.Err == nil && s.BatchSize > 0 && s.Claimed >= s.BatchSize && failures <= s.Claimed/2
+}
+
+// DispatchSettings are the dispatch limits that can be tuned while the service is running
+type DispatchSettings struct {
+	// BatchSize is the most messages claimed by a single poll
+	BatchSize int
+	// Workers is how many messages are sent to the gateway in parallel
+	Workers int
+	// RateLimit is the most messages sent per second, 0 means unlimited
+	RateLimit float64
+}
+
+// ClaimLimit returns how many messages a poll may claim so that every one of them is sent before the
+// lease on it expires, even when each send takes the whole sendTimeout. The rate limit has to let the
+// last message through sendTimeout before the lease ends, and each worker has to finish its share of
+// the batch, ceil(BatchSize/Workers) sends, within the lease. It is never more than BatchSize and never
+// less than one.
+func (s DispatchSettings) ClaimLimit(lease, sendTimeout time.Duration) int {
+	limit := s.BatchSize
+	if s.RateLimit > 0 {
+		window := max(lease-sendTimeout, time.Second)
+		limit = min(limit, int(s.RateLimit*window.Seconds()))
+	}
+	if s.Workers > 0 && sendTimeout > 0 {
+		sendsPerWorker := int((lease - 1) / sendTimeout)
+		limit = min(limit, s.Workers*sendsPerWorker)
+	}
+	return max(limit, 1)
+}
+
+// RateLimitStats describes the outbound rate limit and how often sends had to wait for it
+type RateLimitStats struct {
+	// Backend is redis when the limit is shared by every replica, local when it only covers this one
+	Backend string
+	Rate    float64
+	Burst   float64
+	// Allowed counts sends let through, Throttled those of them that had to wait first
+	Allowed          int64
+	Throttled        int64
+	ThrottledWait    time.Duration
+	MaxThrottledWait time.Duration
+	// Fallbacks counts sends paced by the in-process limiter because Redis could not be reached
+	Fallbacks int64
+}
+
+// PollTotals adds up the summaries of every poll
+type PollTotals struct {
+	Polls int64
+	// FailedPolls counts polls that could not claim messages at all
+	FailedPolls       int64
+	Claimed           int64
+	Sent              int64
+	Failed            int64
+	PermanentlyFailed int64
+	Expired           int64
+	AlreadySent       int64
+	Capped            int64
+	Skipped           int64
+	DeadLettered      int64
+}
+
+// Add counts the outcome of a single poll
+func (t *PollTotals) Add(s PollSummary) {
+	t.Polls++
+	if s.Err != nil {
+		t.FailedPolls++
+	}
+	t.Claimed += int64(s.Claimed)
+	t.Sent += int64(s.Sent)
+	t.Failed += int64(s.Failed)
+	t.PermanentlyFailed += int64(s.PermanentlyFailed)
+	t.Expired += int64(s.Expired) + s.ExpiredBeforeClaim
+	t.AlreadySent += int64(s.AlreadySent)
+	t.Capped += int64(s.Capped)
+	t.Skipped += int64(s.Skipped)
+	t.DeadLettered += s.DeadLettered
+}
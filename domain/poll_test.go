@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPollSummaryIsFullBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary PollSummary
+		want    bool
+	}{
+		{name: "full batch sent", summary: PollSummary{BatchSize: 10, Claimed: 10, Sent: 10}, want: true},
+		{name: "partial batch", summary: PollSummary{BatchSize: 10, Claimed: 9, Sent: 9}, want: false},
+		{name: "half failed still counts", summary: PollSummary{BatchSize: 10, Claimed: 10, Sent: 5, Failed: 5}, want: true},
+		{name: "mostly failed", summary: PollSummary{BatchSize: 10, Claimed: 10, Sent: 4, Failed: 3, PermanentlyFailed: 3}, want: false},
+		{name: "mostly handed back", summary: PollSummary{BatchSize: 10, Claimed: 10, Sent: 2, Skipped: 8}, want: false},
+		{name: "poll failed", summary: PollSummary{BatchSize: 10, Claimed: 10, Err: errors.New("claim failed")}, want: false},
+		{name: "no batch size", summary: PollSummary{}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.summary.IsFullBatch(); got != tt.want {
+				t.Fatalf("IsFullBatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDispatchSettingsClaimLimit(t *testing.T) {
+	const lease, sendTimeout = 5 * time.Minute, 30 * time.Second
+
+	tests := []struct {
+		name     string
+		settings DispatchSettings
+		want     int
+	}{
+		{name: "unlimited claims the batch", settings: DispatchSettings{BatchSize: 100}, want: 100},
+		{name: "rate and workers allow the batch", settings: DispatchSettings{BatchSize: 100, Workers: 20, RateLimit: 10}, want: 100},
+		{name: "rate caps the batch", settings: DispatchSettings{BatchSize: 100, Workers: 20, RateLimit: 0.1}, want: 27},
+		{name: "workers cap the batch", settings: DispatchSettings{BatchSize: 100, Workers: 10}, want: 90},
+		{name: "the tighter cap wins", settings: DispatchSettings{BatchSize: 100, Workers: 2, RateLimit: 0.1}, want: 18},
+		{name: "at least one message", settings: DispatchSettings{BatchSize: 100, RateLimit: 0.001}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.settings.ClaimLimit(lease, sendTimeout); got != tt.want {
+				t.Fatalf("ClaimLimit(%s, %s) = %d, want %d", lease, sendTimeout, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy decides whether and when a failed message is attempted again.
+// The delay before retry n is BaseDelay * Multiplier^(n-1), randomly spread by
+// up to Jitter (a fraction of the delay) either way so messages failed by the
+// same outage do not all come back at once, and never more than MaxDelay.
+type RetryPolicy struct {
+	BaseDelay   time.Duration
+	Multiplier  float64
+	Jitter      float64
+	MaxDelay    time.Duration
+	MaxAttempts int
+}
+
+// ShouldRetry reports whether a message that has failed the given number of times may be sent again
+func (p RetryPolicy) ShouldRetry(failures int) bool {
+	return failures < p.MaxAttempts
+}
+
+// Backoff returns the delay before the next attempt of a message that has failed the given number of times
+func (p RetryPolicy) Backoff(failures int) time.Duration {
+	if failures < 1 {
+		failures = 1
+	}
+	delay := float64(p.BaseDelay) * math.Pow(p.Multiplier, float64(failures-1))
+	// Capping before the jitter as well keeps a delay that grew out of range finite,
+	// at the cap the jitter can then only bring the delay forward
+	delay = p.capDelay(delay)
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(p.capDelay(delay))
+}
+
+func (p RetryPolicy) capDelay(delay float64) float64 {
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		return float64(p.MaxDelay)
+	}
+	return delay
+}
+
+// NextAttemptAt returns when a message that has failed the given number of times should be retried,
+// nil when it has used up its attempts
+func (p RetryPolicy) NextAttemptAt(failures int, now time.Time) *time.Time {
+	if !p.ShouldRetry(failures) {
+		return nil
+	}
+	next := now.Add(p.Backoff(failures))
+	return &next
+}
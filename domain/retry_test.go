@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		failures int
+		min, max time.Duration
+	}{
+		{
+			name:     "first failure waits the base delay",
+			policy:   RetryPolicy{BaseDelay: 30 * time.Second, Multiplier: 2},
+			failures: 1,
+			min:      30 * time.Second,
+			max:      30 * time.Second,
+		},
+		{
+			name:     "failures below one count as the first",
+			policy:   RetryPolicy{BaseDelay: 30 * time.Second, Multiplier: 2},
+			failures: 0,
+			min:      30 * time.Second,
+			max:      30 * time.Second,
+		},
+		{
+			name:     "delay grows by the multiplier",
+			policy:   RetryPolicy{BaseDelay: 30 * time.Second, Multiplier: 2},
+			failures: 4,
+			min:      240 * time.Second,
+			max:      240 * time.Second,
+		},
+		{
+			name:     "delay is capped",
+			policy:   RetryPolicy{BaseDelay: 30 * time.Second, Multiplier: 2, MaxDelay: time.Minute},
+			failures: 10,
+			min:      time.Minute,
+			max:      time.Minute,
+		},
+		{
+			name:     "jitter spreads the delay either way",
+			policy:   RetryPolicy{BaseDelay: 100 * time.Second, Multiplier: 1, Jitter: 0.2},
+			failures: 3,
+			min:      80 * time.Second,
+			max:      120 * time.Second,
+		},
+		{
+			name:     "jitter never pushes the delay past the cap",
+			policy:   RetryPolicy{BaseDelay: time.Hour, Multiplier: 2, Jitter: 0.5, MaxDelay: time.Hour},
+			failures: 5,
+			min:      30 * time.Minute,
+			max:      time.Hour,
+		},
+		{
+			name:     "an overflowing delay stays at the cap",
+			policy:   RetryPolicy{BaseDelay: time.Hour, Multiplier: 10, Jitter: 0.2, MaxDelay: 2 * time.Hour},
+			failures: 400,
+			min:      96 * time.Minute,
+			max:      2 * time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				got := tt.policy.Backoff(tt.failures)
+				if got < tt.min || got > tt.max {
+					t.Fatalf("Backoff(%d) = %s, want between %s and %s", tt.failures, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyNextAttemptAt(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := RetryPolicy{BaseDelay: time.Minute, Multiplier: 2, MaxAttempts: 3}
+
+	tests := []struct {
+		name     string
+		failures int
+		want     *time.Time
+	}{
+		{name: "attempts left", failures: 2, want: ptr(now.Add(2 * time.Minute))},
+		{name: "attempts used up", failures: 3, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.NextAttemptAt(tt.failures, now)
+			switch {
+			case tt.want == nil && got != nil:
+				t.Fatalf("NextAttemptAt(%d) = %s, want nil", tt.failures, got)
+			case tt.want != nil && (got == nil || !got.Equal(*tt.want)):
+				t.Fatalf("NextAttemptAt(%d) = %v, want %s", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/smitendu1997/auto-message-dispatcher/domain"
@@ -42,3 +43,16 @@ func ConvertMessagingResponseToDomainResponse(gatewayResp *MessagingResponse) do
 
 	return domainMessage
 }
+
+// ConvertUnreadableResponseToDomainResponse treats a successful response whose body could not be read as
+// accepted, the provider took the message and sending it again would deliver it twice. The raw body is kept
+// as LastError so the message can be reviewed with the provider.
+func ConvertUnreadableResponseToDomainResponse(statusCode int, body []byte) domain.MessageDomain {
+	sentAt := time.Now()
+	note := fmt.Sprintf("accepted by the messaging service (status %d) with an unreadable response, review with the provider: %s", statusCode, body)
+	return domain.MessageDomain{
+		Status:    domain.MessageStatusSent,
+		SentAt:    &sentAt,
+		LastError: &note,
+	}
+}
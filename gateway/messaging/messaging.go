@@ -23,8 +23,8 @@ package messaging
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/smitendu1997/auto-message-dispatcher/domain"
 	"github.com/smitendu1997/auto-message-dispatcher/gateway/dto"
@@ -33,6 +33,10 @@ import (
 	"github.com/smitendu1997/auto-message-dispatcher/utils"
 )
 
+// idempotencyKeyHeader carries a key derived from the message id, so the provider can drop a retry of
+// a send it already accepted, e.g. one that timed out on our side
+const idempotencyKeyHeader = "Idempotency-Key"
+
 type Messaging struct {
 	ApiKey     string
 	BaseUrl    string
@@ -54,20 +58,34 @@ func (c *Messaging) SendMessage(ctx context.Context, domainReq *domain.MessageDo
 		return domain.MessageDomain{}, err
 	}
 	res, statusCode, err := utils.HttpCall("core.Messaging.SendSMS", ctx, "POST", c.BaseUrl, c.HttpClient, gatewayReqByte, map[string]string{
-		"x-ins-auth-key": c.ApiKey,
-		"Content-Type":   "application/json",
+		"x-ins-auth-key":     c.ApiKey,
+		"Content-Type":       "application/json",
+		idempotencyKeyHeader: idempotencyKey(domainReq.ID),
 	})
 	if err != nil {
-		return domain.MessageDomain{}, err
-	}
-	var result dto.MessagingResponse
-	if err := json.Unmarshal(res, &result); err != nil {
-		return domain.MessageDomain{}, err
+		// Running out of time is not a provider failure, the request may well have been delivered
+		kind := domain.GatewayErrorNetwork
+		if ctx.Err() != nil {
+			kind = domain.GatewayErrorTimeout
+		}
+		return domain.MessageDomain{}, &domain.GatewayError{Kind: kind, StatusCode: statusCode, Err: err}
 	}
 
 	logger.Info("core.Messaging.SendSMS", "Req & Res: ", string(gatewayReqByte), string(res))
-	if statusCode != 202 {
-		return domain.MessageDomain{}, errors.New("failed to send SMS")
+	if statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices {
+		return domain.MessageDomain{}, domain.NewGatewayStatusError(statusCode, res)
+	}
+
+	// The provider took the request, a body we cannot read must not turn into a second send
+	var result dto.MessagingResponse
+	if err := json.Unmarshal(res, &result); err != nil {
+		logger.Error("core.Messaging.SendSMS", "unreadable_accepted_response", statusCode, err)
+		return dto.ConvertUnreadableResponseToDomainResponse(statusCode, res), nil
 	}
 	return dto.ConvertMessagingResponseToDomainResponse(&result), nil
 }
+
+// idempotencyKey is the same for every attempt at sending a message
+func idempotencyKey(messageID int64) string {
+	return "message-" + strconv.FormatInt(messageID, 10)
+}
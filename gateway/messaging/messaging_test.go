@@ -0,0 +1,139 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+)
+
+func TestSendMessageClassifiesResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		// wantKind is the gateway error kind, empty when the message is accepted
+		wantKind      domain.GatewayErrorKind
+		wantRetryable bool
+		wantMessageID string
+	}{
+		{name: "accepted", status: http.StatusAccepted, body: `{"message":"Accepted","messageId":"abc"}`, wantMessageID: "abc"},
+		{name: "accepted with an unreadable body", status: http.StatusAccepted, body: `<html>`},
+		{name: "throttled", status: http.StatusTooManyRequests, wantKind: domain.GatewayErrorThrottled, wantRetryable: true},
+		{name: "request timeout", status: http.StatusRequestTimeout, wantKind: domain.GatewayErrorNetwork, wantRetryable: true},
+		{name: "server error", status: http.StatusBadGateway, wantKind: domain.GatewayErrorServer, wantRetryable: true},
+		{name: "invalid recipient", status: http.StatusBadRequest, body: `{"error":"invalid number"}`, wantKind: domain.GatewayErrorRejected},
+		{name: "unexpected status", status: http.StatusNotModified, wantKind: domain.GatewayErrorMalformedResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if key := r.Header.Get(idempotencyKeyHeader); key != "message-42" {
+					t.Errorf("%s = %q, want message-42", idempotencyKeyHeader, key)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			gateway := NewMessagingGateway(http.Client{}, server.URL, "key")
+			response, err := gateway.SendMessage(context.Background(), &domain.MessageDomain{ID: 42, RecipientPhone: "+905551111111", Content: "hello"})
+
+			if tt.wantKind == "" {
+				if err != nil {
+					t.Fatalf("error = %v, want the message accepted", err)
+				}
+				if response.Status != domain.MessageStatusSent {
+					t.Fatalf("status = %s, want sent", response.Status)
+				}
+				if tt.wantMessageID != "" && (response.MessageID == nil || *response.MessageID != tt.wantMessageID) {
+					t.Fatalf("message id = %v, want %s", response.MessageID, tt.wantMessageID)
+				}
+				return
+			}
+
+			var gatewayErr *domain.GatewayError
+			if !errors.As(err, &gatewayErr) {
+				t.Fatalf("error = %v, want a gateway error", err)
+			}
+			if gatewayErr.Kind != tt.wantKind || gatewayErr.StatusCode != tt.status || gatewayErr.Body != tt.body {
+				t.Fatalf("error = %s with status %d and body %q, want %s with status %d and body %q",
+					gatewayErr.Kind, gatewayErr.StatusCode, gatewayErr.Body, tt.wantKind, tt.status, tt.body)
+			}
+			if retryable := domain.IsRetryableGatewayError(err); retryable != tt.wantRetryable {
+				t.Fatalf("retryable = %v, want %v", retryable, tt.wantRetryable)
+			}
+		})
+	}
+}
+
+func TestSendMessageWithoutAnswer(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		closed   bool
+		wantKind domain.GatewayErrorKind
+	}{
+		{name: "send timeout", timeout: 50 * time.Millisecond, wantKind: domain.GatewayErrorTimeout},
+		{name: "provider unreachable", timeout: time.Second, closed: true, wantKind: domain.GatewayErrorNetwork},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				<-release
+			}))
+			defer server.Close()
+			defer close(release)
+			if tt.closed {
+				server.Listener.Close()
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			gateway := NewMessagingGateway(http.Client{}, server.URL, "key")
+			_, err := gateway.SendMessage(ctx, &domain.MessageDomain{ID: 42})
+
+			var gatewayErr *domain.GatewayError
+			if !errors.As(err, &gatewayErr) || gatewayErr.Kind != tt.wantKind {
+				t.Fatalf("error = %v, want a %s gateway error", err, tt.wantKind)
+			}
+			if !domain.IsRetryableGatewayError(err) {
+				t.Fatalf("%s error is not retried", tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestSendMessageCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	gateway := NewMessagingGateway(http.Client{}, server.URL, "key")
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := gateway.SendMessage(ctx, &domain.MessageDomain{ID: 42})
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Fatal("send succeeded, want it cancelled")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancelling the context did not interrupt the send")
+	}
+}
@@ -1,9 +1,15 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
 	handlerDto "github.com/smitendu1997/auto-message-dispatcher/handler/messaging/dto"
 	"github.com/smitendu1997/auto-message-dispatcher/handler/messaging/poller"
 	"github.com/smitendu1997/auto-message-dispatcher/logger"
@@ -15,19 +21,39 @@ import (
 type MessageAPIHandler interface {
 	StartWorker() gin.HandlerFunc
 	StopWorker() gin.HandlerFunc
+	GetWorkerConfig() gin.HandlerFunc
+	UpdateWorkerConfig() gin.HandlerFunc
+	ResetWorkerConfig() gin.HandlerFunc
+	PollNow() gin.HandlerFunc
+	GetWorkerStatus() gin.HandlerFunc
 	ListSentMessages() gin.HandlerFunc
+	ListExpiredMessages() gin.HandlerFunc
+	CreateMessage() gin.HandlerFunc
+	CreateMessagesBulk() gin.HandlerFunc
+	CancelMessage() gin.HandlerFunc
+	GetMessage() gin.HandlerFunc
+	SearchMessages() gin.HandlerFunc
+	ExportMessages() gin.HandlerFunc
+	ListDeadMessages() gin.HandlerFunc
+	RequeueMessage() gin.HandlerFunc
+	RequeueMessages() gin.HandlerFunc
 }
 
 type messageAPIHandler struct {
 	handler          *poller.MessageHandler
 	messagingService messagingService.MessagingSvcDriver
+	bulkMaxBatchSize int
+	// pollOnCreate wakes the poller when a message that is already due is created
+	pollOnCreate bool
 }
 
 // NewMessageAPIHandler creates a new message API handler
-func NewMessageAPIHandler(handler *poller.MessageHandler, messagingService messagingService.MessagingSvcDriver) MessageAPIHandler {
+func NewMessageAPIHandler(handler *poller.MessageHandler, messagingService messagingService.MessagingSvcDriver, bulkMaxBatchSize int, pollOnCreate bool) MessageAPIHandler {
 	return &messageAPIHandler{
 		handler:          handler,
 		messagingService: messagingService,
+		bulkMaxBatchSize: bulkMaxBatchSize,
+		pollOnCreate:     pollOnCreate,
 	}
 }
 
@@ -37,6 +63,11 @@ func (h *messageAPIHandler) StartWorker() gin.HandlerFunc {
 		const functionName = "api.messageAPIHandler.StartWorker"
 
 		err := h.handler.Start()
+		if errors.Is(err, poller.ErrPollerStopping) {
+			response := utils.ResponseWithModel("409", "Worker is still stopping, try again shortly", nil)
+			c.JSON(http.StatusConflict, response)
+			return
+		}
 		if err != nil {
 			logger.Error(functionName, "failed to start Message API:", err)
 			response := utils.ResponseWithModel("500", "Failed to start Message API", nil)
@@ -71,13 +102,175 @@ func (h *messageAPIHandler) StopWorker() gin.HandlerFunc {
 	}
 }
 
+// GetWorkerConfig returns the poll interval and dispatch settings the poller is running with
+func (h *messageAPIHandler) GetWorkerConfig() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.GetWorkerConfig"
+
+		h.handler.SyncSettings(c.Request.Context())
+		logger.Info(functionName, "worker config retrieved successfully")
+		response := utils.ResponseWithModel("200", "Worker config retrieved successfully", handlerDto.ConvertPollerSettingsToResponse(h.handler.Settings()))
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// UpdateWorkerConfig changes the poll interval and dispatch settings of the poller on every replica with the same configuration, the new values apply from the next poll
+func (h *messageAPIHandler) UpdateWorkerConfig() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.UpdateWorkerConfig"
+
+		var configReq handlerDto.UpdatePollerConfigRequest
+		if err := c.ShouldBindJSON(&configReq); err != nil {
+			logger.Error(functionName, "failed to bind request body:", err)
+			response := utils.ResponseWithModel("400", "Invalid request body", nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		if err := configReq.Validate(); err != nil {
+			logger.Error(functionName, "invalid worker config:", err)
+			response := utils.ResponseWithModel("400", err.Error(), nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		h.handler.SyncSettings(c.Request.Context())
+		settings, err := h.handler.UpdateSettings(c.Request.Context(), configReq.ApplyTo(h.handler.Settings()))
+		if errors.Is(err, domain.ErrBatchOutlastsLease) {
+			logger.Error(functionName, "invalid worker config:", err)
+			response := utils.ResponseWithModel("400", err.Error(), nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+		if errors.Is(err, poller.ErrSettingsNotShared) {
+			logger.Error(functionName, "failed to share worker config:", err)
+			response := utils.ResponseWithModel("503", "Worker config could not be shared with the other replicas, nothing was changed", nil)
+			c.JSON(http.StatusServiceUnavailable, response)
+			return
+		}
+		if err != nil {
+			logger.Error(functionName, "failed to update worker config:", err)
+			response := utils.ResponseWithModel("500", "Failed to update worker config", nil)
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		logger.Info(functionName, "worker config updated successfully", "poll_interval", settings.PollInterval.String(),
+			"batch_size", settings.Dispatch.BatchSize, "workers", settings.Dispatch.Workers, "rate_limit", settings.Dispatch.RateLimit)
+		response := utils.ResponseWithModel("200", "Worker config updated successfully", handlerDto.ConvertPollerSettingsToResponse(settings))
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// ResetWorkerConfig drops the settings changed at runtime and goes back to the configured ones on every replica
+func (h *messageAPIHandler) ResetWorkerConfig() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.ResetWorkerConfig"
+
+		settings, err := h.handler.ResetSettings(c.Request.Context())
+		if errors.Is(err, poller.ErrSettingsNotShared) {
+			logger.Error(functionName, "failed to reset shared worker config:", err)
+			response := utils.ResponseWithModel("503", "Worker config could not be reset on the other replicas, nothing was changed", nil)
+			c.JSON(http.StatusServiceUnavailable, response)
+			return
+		}
+		if err != nil {
+			logger.Error(functionName, "failed to reset worker config:", err)
+			response := utils.ResponseWithModel("500", "Failed to reset worker config", nil)
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		logger.Info(functionName, "worker config reset successfully", "poll_interval", settings.PollInterval.String(),
+			"batch_size", settings.Dispatch.BatchSize, "workers", settings.Dispatch.Workers, "rate_limit", settings.Dispatch.RateLimit)
+		response := utils.ResponseWithModel("200", "Worker config reset successfully", handlerDto.ConvertPollerSettingsToResponse(settings))
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// PollNow wakes the poller to dispatch due messages straight away
+func (h *messageAPIHandler) PollNow() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.PollNow"
+
+		result, err := h.handler.PollNow(c.Request.Context())
+		if errors.Is(err, poller.ErrPollerNotRunning) {
+			response := utils.ResponseWithModel("409", "Worker is not running", nil)
+			c.JSON(http.StatusConflict, response)
+			return
+		}
+		if errors.Is(err, poller.ErrNotLeader) {
+			_, leaderID := h.handler.Leader()
+			response := utils.ResponseWithModel("409", "This replica is not polling, send the request to the leader", handlerDto.NotLeaderResponse{LeaderID: leaderID})
+			c.JSON(http.StatusConflict, response)
+			return
+		}
+		if errors.Is(err, poller.ErrPollNotForwarded) {
+			logger.Error(functionName, "failed to forward poll request:", err)
+			response := utils.ResponseWithModel("503", "Poll request could not be forwarded to the leader", nil)
+			c.JSON(http.StatusServiceUnavailable, response)
+			return
+		}
+
+		logger.Info(functionName, "immediate poll requested", "queued", result.Queued, "forwarded", result.Forwarded)
+		response := utils.ResponseWithModel("202", "Poll requested", handlerDto.PollNowResponse{Coalesced: !result.Queued, Forwarded: result.Forwarded})
+		c.JSON(http.StatusAccepted, response)
+	}
+}
+
+// GetWorkerStatus reports whether the poller is running, what its last poll did and when it polls next
+func (h *messageAPIHandler) GetWorkerStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.GetWorkerStatus"
+
+		logger.Info(functionName, "worker status retrieved successfully")
+		response := utils.ResponseWithModel("200", "Worker status retrieved successfully", handlerDto.ConvertPollerStatusToResponse(h.handler.Status()))
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// nudgePoller wakes the poller after messages were created if any of them can be sent right away,
+// on a follower the request is forwarded to the leader
+func (h *messageAPIHandler) nudgePoller(ctx context.Context, msgs []*domain.MessageDomain) {
+	const functionName = "api.messageAPIHandler.nudgePoller"
+	if !h.pollOnCreate {
+		return
+	}
+	now := time.Now()
+	for _, msg := range msgs {
+		if msg.IsDue(now) {
+			if _, err := h.handler.PollNow(ctx); err != nil {
+				logger.Info(functionName, "poller not woken", err.Error())
+			}
+			return
+		}
+	}
+}
+
 // ListSentMessages lists all sent messages with pagination
 func (h *messageAPIHandler) ListSentMessages() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		const functionName = "api.messageAPIHandler.ListSentMessages"
+	return h.listMessages("api.messageAPIHandler.ListSentMessages", domain.MessageStatusSent, h.messagingService.ListSentMessages)
+}
+
+// ListExpiredMessages lists all expired messages with pagination
+func (h *messageAPIHandler) ListExpiredMessages() gin.HandlerFunc {
+	return h.listMessages("api.messageAPIHandler.ListExpiredMessages", domain.MessageStatusExpired, h.messagingService.ListExpiredMessages)
+}
 
+// ListDeadMessages lists messages that used up their retry attempts with pagination
+func (h *messageAPIHandler) ListDeadMessages() gin.HandlerFunc {
+	return h.listMessages("api.messageAPIHandler.ListDeadMessages", domain.MessageStatusDead, h.messagingService.ListDeadMessages)
+}
+
+// messageLister fetches one page of the messages in a single status
+type messageLister func(ctx context.Context, page domain.PageRequest) (*domain.MessagePage, error)
+
+// listMessages is shared by the list endpoints, which only differ in the status they list
+func (h *messageAPIHandler) listMessages(functionName string, status domain.MessageStatus, list messageLister) gin.HandlerFunc {
+	label := strings.ToUpper(string(status[:1])) + string(status[1:])
+	return func(c *gin.Context) {
 		// Parse pagination parameters
-		var paginationReq handlerDto.ListSentMessagesRequest
+		var paginationReq handlerDto.ListMessagesRequest
 		if err := c.ShouldBindQuery(&paginationReq); err != nil {
 			logger.Error(functionName, "failed to bind query parameters:", err)
 			response := utils.ResponseWithModel("400", "Invalid query parameters", nil)
@@ -86,32 +279,443 @@ func (h *messageAPIHandler) ListSentMessages() gin.HandlerFunc {
 		}
 
 		// Validate and set defaults
-		paginationReq.ValidateAndSetDefaults()
+		paginationReq.ValidateAndSetDefaults(status)
+		page, err := paginationReq.ToPageRequest(status)
+		if err != nil {
+			logger.Error(functionName, "invalid cursor:", err)
+			response := utils.ResponseWithModel("400", err.Error(), nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
 
-		// Get sent messages from service
-		messages, totalCount, hasMore, err := h.messagingService.ListSentMessages(c.Request.Context(), paginationReq.Limit, paginationReq.Offset)
+		result, err := list(c.Request.Context(), page)
 		if err != nil {
-			logger.Error(functionName, "failed to get sent messages:", err)
-			response := utils.ResponseWithModel("500", "Failed to retrieve sent messages", nil)
+			logger.Error(functionName, "failed to get messages:", err)
+			response := utils.ResponseWithModel("500", "Failed to retrieve "+string(status)+" messages", nil)
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		logger.Info(functionName, "messages retrieved successfully", "status", status, "count", len(result.Messages), "hasMore", result.HasMore)
+		response := utils.ResponseWithModel("200", label+" messages retrieved successfully", handlerDto.NewListMessagesResponse(status, page, result))
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// CreateMessage enqueues a new pending message for the poller to dispatch
+func (h *messageAPIHandler) CreateMessage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.CreateMessage"
+
+		var createReq handlerDto.CreateMessageRequest
+		if err := c.ShouldBindJSON(&createReq); err != nil {
+			logger.Error(functionName, "failed to bind request body:", err)
+			response := utils.ResponseWithModel("400", "Invalid request body", nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		if err := createReq.ApplyIdempotencyKey(c.GetHeader(handlerDto.IdempotencyKeyHeader)); err != nil {
+			logger.Error(functionName, "invalid idempotency key:", err)
+			response := utils.ResponseWithModel("400", err.Error(), nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		msg := createReq.ToDomain()
+		if err := msg.Validate(); err != nil {
+			logger.Error(functionName, "invalid message:", err)
+			response := utils.ResponseWithModel("400", err.Error(), nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		created, err := h.messagingService.CreateMessage(c.Request.Context(), msg)
+		if errors.Is(err, domain.ErrClientReferenceReused) {
+			logger.Error(functionName, "client reference reused:", err)
+			response := utils.ResponseWithModel("422", err.Error(), nil)
+			c.JSON(http.StatusUnprocessableEntity, response)
+			return
+		}
+		if err != nil {
+			logger.Error(functionName, "failed to create message:", err)
+			response := utils.ResponseWithModel("500", "Failed to create message", nil)
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		if created.Replayed {
+			logger.Info(functionName, "message already exists for client reference", "id", created.Message.ID)
+			response := utils.ResponseWithModel("200", "Message already exists", handlerDto.ConvertCreatedMessageToResponse(created))
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		h.nudgePoller(c.Request.Context(), []*domain.MessageDomain{created.Message})
+		logger.Info(functionName, "message created successfully", "id", created.Message.ID)
+		response := utils.ResponseWithModel("201", "Message created successfully", handlerDto.ConvertCreatedMessageToResponse(created))
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// CreateMessagesBulk enqueues a batch of messages sent as a JSON array or NDJSON body
+// and reports the outcome of every item
+func (h *messageAPIHandler) CreateMessagesBulk() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.CreateMessagesBulk"
+
+		// The body is parsed as it is read, a body larger than any acceptable batch is cut off early
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, handlerDto.MaxBulkBodyBytes(h.bulkMaxBatchSize))
+		var (
+			items []handlerDto.BulkCreateMessageItem
+			err   error
+		)
+		if handlerDto.IsNDJSONContentType(c.ContentType()) {
+			items, err = handlerDto.ParseBulkCreateMessagesNDJSON(body, h.bulkMaxBatchSize)
+		} else {
+			items, err = handlerDto.ParseBulkCreateMessagesJSON(body, h.bulkMaxBatchSize)
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.Is(err, handlerDto.ErrBulkBatchTooLarge) || errors.Is(err, handlerDto.ErrBulkItemTooLarge) || errors.As(err, &maxBytesErr) {
+			logger.Error(functionName, "batch too large:", err)
+			response := utils.ResponseWithModel("413", err.Error(), map[string]interface{}{
+				"max_batch_size": h.bulkMaxBatchSize,
+				"max_item_bytes": handlerDto.MaxBulkItemBytes,
+			})
+			c.JSON(http.StatusRequestEntityTooLarge, response)
+			return
+		}
+		if err != nil {
+			logger.Error(functionName, "failed to parse request body:", err)
+			response := utils.ResponseWithModel("400", "Invalid request body", nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		// Validate every item, only valid ones are persisted
+		results := make([]handlerDto.BulkCreateMessageResult, len(items))
+		validMsgs := make([]*domain.MessageDomain, 0, len(items))
+		validIndexes := make([]int, 0, len(items))
+		for i, item := range items {
+			results[i] = handlerDto.BulkCreateMessageResult{Index: i, Status: handlerDto.BulkItemStatusRejected}
+			if item.ParseErr != nil {
+				results[i].Reason = item.ParseErr.Error()
+				continue
+			}
+			msg := item.Request.ToDomain()
+			if err := msg.Validate(); err != nil {
+				results[i].Reason = err.Error()
+				continue
+			}
+			validMsgs = append(validMsgs, msg)
+			validIndexes = append(validIndexes, i)
+		}
+
+		if len(validMsgs) > 0 {
+			created, err := h.messagingService.CreateMessages(c.Request.Context(), validMsgs)
+			if errors.Is(err, domain.ErrDuplicateClientReference) {
+				logger.Error(functionName, "concurrent client reference conflict:", err)
+				response := utils.ResponseWithModel("409", "A client_reference in the batch was created concurrently, retry the batch", nil)
+				c.JSON(http.StatusConflict, response)
+				return
+			}
+			if err != nil {
+				logger.Error(functionName, "failed to create messages:", err)
+				response := utils.ResponseWithModel("500", "Failed to create messages", nil)
+				c.JSON(http.StatusInternalServerError, response)
+				return
+			}
+			newMsgs := make([]*domain.MessageDomain, 0, len(created))
+			for i, result := range created {
+				if result.Err != nil {
+					results[validIndexes[i]].Reason = result.Err.Error()
+					continue
+				}
+				results[validIndexes[i]].Status = handlerDto.BulkItemStatusAccepted
+				results[validIndexes[i]].ID = result.Message.ID
+				results[validIndexes[i]].Replayed = result.Replayed
+				if !result.Replayed {
+					newMsgs = append(newMsgs, result.Message)
+				}
+			}
+			h.nudgePoller(c.Request.Context(), newMsgs)
+		}
+
+		bulkResponse := handlerDto.BulkCreateMessagesResponse{Results: results}
+		for _, result := range results {
+			if result.Status == handlerDto.BulkItemStatusAccepted {
+				bulkResponse.Accepted++
+			} else {
+				bulkResponse.Rejected++
+			}
+		}
+
+		logger.Info(functionName, "bulk messages processed", "accepted", bulkResponse.Accepted, "rejected", bulkResponse.Rejected)
+		response := utils.ResponseWithModel("200", "Bulk messages processed", bulkResponse)
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// CancelMessage cancels a pending or failed message before the poller dispatches it
+func (h *messageAPIHandler) CancelMessage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.CancelMessage"
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			logger.Error(functionName, "invalid message id:", c.Param("id"))
+			response := utils.ResponseWithModel("400", "Invalid message id", nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		msg, err := h.messagingService.CancelMessage(c.Request.Context(), id)
+		if errors.Is(err, domain.ErrMessageNotFound) {
+			response := utils.ResponseWithModel("404", "Message not found", nil)
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		if errors.Is(err, domain.ErrMessageNotCancellable) {
+			response := utils.ResponseWithModel("409", err.Error(), handlerDto.ConvertDomainToMessageResponse(msg))
+			c.JSON(http.StatusConflict, response)
+			return
+		}
+		if err != nil {
+			logger.Error(functionName, "failed to cancel message:", err)
+			response := utils.ResponseWithModel("500", "Failed to cancel message", nil)
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		logger.Info(functionName, "message cancelled successfully", "id", id)
+		response := utils.ResponseWithModel("200", "Message cancelled successfully", handlerDto.ConvertDomainToMessageResponse(msg))
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// GetMessage returns a single message with its full delivery history
+func (h *messageAPIHandler) GetMessage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.GetMessage"
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			logger.Error(functionName, "invalid message id:", c.Param("id"))
+			response := utils.ResponseWithModel("400", "Invalid message id", nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		msg, attempts, err := h.messagingService.GetMessage(c.Request.Context(), id)
+		if errors.Is(err, domain.ErrMessageNotFound) {
+			response := utils.ResponseWithModel("404", "Message not found", nil)
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		if err != nil {
+			logger.Error(functionName, "failed to get message:", err)
+			response := utils.ResponseWithModel("500", "Failed to retrieve message", nil)
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		logger.Info(functionName, "message retrieved successfully", "id", id)
+		response := utils.ResponseWithModel("200", "Message retrieved successfully", handlerDto.ConvertDomainToMessageDetailResponse(msg, attempts))
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// SearchMessages lists messages in any status matching the given filters with pagination
+func (h *messageAPIHandler) SearchMessages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.SearchMessages"
+
+		// Parse filter and pagination parameters
+		var searchReq handlerDto.SearchMessagesRequest
+		if err := c.ShouldBindQuery(&searchReq); err != nil {
+			logger.Error(functionName, "failed to bind query parameters:", err)
+			response := utils.ResponseWithModel("400", "Invalid query parameters", nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		// Validate and set defaults
+		if err := searchReq.ValidateAndSetDefaults(); err != nil {
+			logger.Error(functionName, "invalid query parameters:", err)
+			response := utils.ResponseWithModel("400", err.Error(), nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		page, err := searchReq.ToPageRequest()
+		if err != nil {
+			logger.Error(functionName, "invalid cursor:", err)
+			response := utils.ResponseWithModel("400", err.Error(), nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		result, err := h.messagingService.SearchMessages(c.Request.Context(), searchReq.ToDomain(), page)
+		if err != nil {
+			logger.Error(functionName, "failed to search messages:", err)
+			response := utils.ResponseWithModel("500", "Failed to retrieve messages", nil)
 			c.JSON(http.StatusInternalServerError, response)
 			return
 		}
 
-		// Convert domain messages to handler response format
-		messageResponses := handlerDto.ConvertServiceResponseToHandlerResponse(messages)
 		// Build response
-		sentMessagesResponse := handlerDto.ListSentMessagesResponse{
-			Messages: messageResponses,
-			Pagination: handlerDto.PaginationMetadata{
-				Limit:   paginationReq.Limit,
-				Offset:  paginationReq.Offset,
-				Total:   totalCount,
-				HasMore: hasMore,
-			},
-		}
-
-		logger.Info(functionName, "sent messages retrieved successfully", "count", len(messages), "total", totalCount)
-		response := utils.ResponseWithModel("200", "Sent messages retrieved successfully", sentMessagesResponse)
+		searchResponse := handlerDto.SearchMessagesResponse{
+			Messages:   handlerDto.ConvertServiceResponseToHandlerResponse(result.Messages),
+			Pagination: handlerDto.NewPaginationMetadata(handlerDto.CursorScopeSearch, page, result),
+		}
+
+		logger.Info(functionName, "messages retrieved successfully", "count", len(result.Messages), "hasMore", result.HasMore)
+		response := utils.ResponseWithModel("200", "Messages retrieved successfully", searchResponse)
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// exportFlushInterval is the number of rows written between flushes to the client
+const exportFlushInterval = 500
+
+// ExportMessages streams every message matching the search filters as CSV or NDJSON
+func (h *messageAPIHandler) ExportMessages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.ExportMessages"
+
+		var exportReq handlerDto.ExportMessagesRequest
+		if err := c.ShouldBindQuery(&exportReq); err != nil {
+			logger.Error(functionName, "failed to bind query parameters:", err)
+			response := utils.ResponseWithModel("400", "Invalid query parameters", nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		if err := exportReq.ValidateAndSetDefaults(); err != nil {
+			logger.Error(functionName, "invalid query parameters:", err)
+			response := utils.ResponseWithModel("400", err.Error(), nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		writer, err := handlerDto.NewMessageExportWriter(exportReq.Format, c.Writer)
+		if err != nil {
+			logger.Error(functionName, "failed to create export writer:", err)
+			response := utils.ResponseWithModel("500", "Failed to export messages", nil)
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		c.Header("Content-Type", exportReq.ContentType())
+		c.Header("Content-Disposition", `attachment; filename="`+exportReq.FileName(time.Now())+`"`)
+		c.Status(http.StatusOK)
+
+		var count int
+		err = h.messagingService.ExportMessages(c.Request.Context(), exportReq.ToDomain(), func(msg *domain.MessageDomain) error {
+			if err := writer.Write(handlerDto.ConvertDomainToMessageResponse(msg)); err != nil {
+				return err
+			}
+			count++
+			if count%exportFlushInterval == 0 {
+				if err := writer.Flush(); err != nil {
+					return err
+				}
+				c.Writer.Flush()
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error(functionName, "failed to export messages:", err, "exported", count)
+			if !c.Writer.Written() {
+				// Nothing has reached the client yet so a proper error response can still be sent
+				c.Header("Content-Disposition", "")
+				c.Header("Content-Type", "")
+				response := utils.ResponseWithModel("500", "Failed to export messages", nil)
+				c.JSON(http.StatusInternalServerError, response)
+				return
+			}
+			// The body is already partially streamed so the failure can only be logged
+			c.Abort()
+			return
+		}
+
+		if err := writer.Flush(); err != nil {
+			logger.Error(functionName, "failed to flush export:", err)
+			return
+		}
+		c.Writer.Flush()
+
+		logger.Info(functionName, "messages exported successfully", "count", count, "format", exportReq.Format)
+	}
+}
+
+// RequeueMessage sends a single dead-lettered message back to pending
+func (h *messageAPIHandler) RequeueMessage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.RequeueMessage"
+
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil || id <= 0 {
+			logger.Error(functionName, "invalid message id:", c.Param("id"))
+			response := utils.ResponseWithModel("400", "Invalid message id", nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		msg, err := h.messagingService.RequeueMessage(c.Request.Context(), id)
+		if errors.Is(err, domain.ErrMessageNotFound) {
+			response := utils.ResponseWithModel("404", "Message not found", nil)
+			c.JSON(http.StatusNotFound, response)
+			return
+		}
+		if errors.Is(err, domain.ErrMessageNotRequeueable) {
+			response := utils.ResponseWithModel("409", "Only dead-lettered messages can be requeued", handlerDto.ConvertDomainToMessageResponse(msg))
+			c.JSON(http.StatusConflict, response)
+			return
+		}
+		if err != nil {
+			logger.Error(functionName, "failed to requeue message:", err)
+			response := utils.ResponseWithModel("500", "Failed to requeue message", nil)
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		logger.Info(functionName, "message requeued successfully", "id", id)
+		response := utils.ResponseWithModel("200", "Message requeued successfully", handlerDto.ConvertDomainToMessageResponse(msg))
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// RequeueMessages sends every dead-lettered message matching the filters back to pending
+func (h *messageAPIHandler) RequeueMessages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		const functionName = "api.messageAPIHandler.RequeueMessages"
+
+		var requeueReq handlerDto.RequeueMessagesRequest
+		if err := c.ShouldBindQuery(&requeueReq); err != nil {
+			logger.Error(functionName, "failed to bind query parameters:", err)
+			response := utils.ResponseWithModel("400", "Invalid query parameters", nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		if err := requeueReq.Validate(); err != nil {
+			logger.Error(functionName, "invalid query parameters:", err)
+			response := utils.ResponseWithModel("400", err.Error(), nil)
+			c.JSON(http.StatusBadRequest, response)
+			return
+		}
+
+		count, err := h.messagingService.RequeueMessages(c.Request.Context(), requeueReq.ToDomain())
+		if err != nil {
+			logger.Error(functionName, "failed to requeue messages:", err)
+			response := utils.ResponseWithModel("500", "Failed to requeue messages", nil)
+			c.JSON(http.StatusInternalServerError, response)
+			return
+		}
+
+		logger.Info(functionName, "messages requeued successfully", "count", count)
+		response := utils.ResponseWithModel("200", "Messages requeued successfully", handlerDto.RequeueMessagesResponse{Requeued: count})
 		c.JSON(http.StatusOK, response)
 	}
 }
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+	handlerDto "github.com/smitendu1997/auto-message-dispatcher/handler/messaging/dto"
+	messagingService "github.com/smitendu1997/auto-message-dispatcher/services/messaging"
+)
+
+// fakeMessagingService answers message creation with fixed results. Other calls are not
+// implemented and panic through the embedded nil interface.
+type fakeMessagingService struct {
+	messagingService.MessagingSvcDriver
+	created *domain.CreatedMessage
+	err     error
+}
+
+func (s *fakeMessagingService) CreateMessage(ctx context.Context, msg *domain.MessageDomain) (*domain.CreatedMessage, error) {
+	return s.created, s.err
+}
+
+func (s *fakeMessagingService) CreateMessages(ctx context.Context, msgs []*domain.MessageDomain) ([]*domain.CreatedMessage, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	results := make([]*domain.CreatedMessage, len(msgs))
+	for i := range msgs {
+		results[i] = s.created
+	}
+	return results, nil
+}
+
+// serve runs one request through handler and returns the response status
+func serve(t *testing.T, handler gin.HandlerFunc, contentType, body string, headers map[string]string) int {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/", handler)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestCreateMessageIdempotency(t *testing.T) {
+	const body = `{"recipient_phone":"+905551111111","content":"hello"}`
+	msg := &domain.MessageDomain{ID: 7, RecipientPhone: "+905551111111", Content: "hello", Status: domain.MessageStatusPending}
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		created    *domain.CreatedMessage
+		err        error
+		wantStatus int
+	}{
+		{name: "new message", created: &domain.CreatedMessage{Message: msg}, wantStatus: http.StatusCreated},
+		{name: "replayed message", headers: map[string]string{handlerDto.IdempotencyKeyHeader: "order-1"}, created: &domain.CreatedMessage{Message: msg, Replayed: true}, wantStatus: http.StatusOK},
+		{name: "key reused for another message", headers: map[string]string{handlerDto.IdempotencyKeyHeader: "order-1"}, err: domain.ErrClientReferenceReused, wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewMessageAPIHandler(nil, &fakeMessagingService{created: tt.created, err: tt.err}, 10, false)
+			if status := serve(t, h.CreateMessage(), "application/json", body, tt.headers); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCreateMessagesBulkConcurrentDuplicate(t *testing.T) {
+	const body = `[{"recipient_phone":"+905551111111","content":"hello","client_reference":"order-1"}]`
+	h := NewMessageAPIHandler(nil, &fakeMessagingService{err: domain.ErrDuplicateClientReference}, 10, false)
+	if status := serve(t, h.CreateMessagesBulk(), "application/json", body, nil); status != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", status, http.StatusConflict)
+	}
+}
@@ -0,0 +1,83 @@
+package dto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+)
+
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+// CursorScope names the listing, and with it the sort order, a cursor was issued for. A cursor
+// is only accepted by the listing that issued it. The list APIs use the status they list as scope.
+type CursorScope string
+
+const CursorScopeSearch CursorScope = "search"
+
+// cursorPayload is the JSON shape behind the opaque cursor handed out to clients
+type cursorPayload struct {
+	Scope    CursorScope `json:"s"`
+	SortTime *time.Time  `json:"t,omitempty"`
+	ID       int64       `json:"id"`
+}
+
+// EncodeCursor turns a message cursor into an opaque URL safe token bound to scope
+func EncodeCursor(scope CursorScope, cursor *domain.MessageCursor) string {
+	if cursor == nil {
+		return ""
+	}
+	payload, err := json.Marshal(cursorPayload{Scope: scope, SortTime: cursor.SortTime, ID: cursor.ID})
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// DecodeCursor parses a token produced by EncodeCursor for the same scope
+func DecodeCursor(scope CursorScope, token string) (*domain.MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil || payload.ID <= 0 || payload.Scope != scope {
+		return nil, ErrInvalidCursor
+	}
+	return &domain.MessageCursor{SortTime: payload.SortTime, ID: payload.ID}, nil
+}
+
+// toPageRequest builds the domain page request. A cursor switches to keyset pagination, in which
+// case the total is only counted when explicitly requested since it is the expensive part.
+func toPageRequest(scope CursorScope, limit, offset int32, cursor string, includeTotal *bool) (domain.PageRequest, error) {
+	page := domain.PageRequest{
+		Limit:        limit,
+		Offset:       offset,
+		IncludeTotal: cursor == "",
+	}
+	if includeTotal != nil {
+		page.IncludeTotal = *includeTotal
+	}
+	if cursor != "" {
+		decoded, err := DecodeCursor(scope, cursor)
+		if err != nil {
+			return domain.PageRequest{}, err
+		}
+		page.Cursor = decoded
+		page.Offset = 0
+	}
+	return page, nil
+}
+
+// NewPaginationMetadata builds the pagination block of a listing response, the next cursor is bound to scope
+func NewPaginationMetadata(scope CursorScope, page domain.PageRequest, result *domain.MessagePage) PaginationMetadata {
+	return PaginationMetadata{
+		Limit:      page.Limit,
+		Offset:     page.Offset,
+		Total:      result.Total,
+		HasMore:    result.HasMore,
+		NextCursor: EncodeCursor(scope, result.NextCursor),
+	}
+}
@@ -0,0 +1,101 @@
+package dto
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	sentAt := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		cursor *domain.MessageCursor
+	}{
+		{name: "with sort time", cursor: &domain.MessageCursor{SortTime: &sentAt, ID: 42}},
+		{name: "without sort time", cursor: &domain.MessageCursor{ID: 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := EncodeCursor(CursorScopeSearch, tt.cursor)
+			got, err := DecodeCursor(CursorScopeSearch, token)
+			if err != nil {
+				t.Fatalf("DecodeCursor(%q) error = %v", token, err)
+			}
+			if got.ID != tt.cursor.ID {
+				t.Fatalf("ID = %d, want %d", got.ID, tt.cursor.ID)
+			}
+			if (got.SortTime == nil) != (tt.cursor.SortTime == nil) ||
+				(got.SortTime != nil && !got.SortTime.Equal(*tt.cursor.SortTime)) {
+				t.Fatalf("SortTime = %v, want %v", got.SortTime, tt.cursor.SortTime)
+			}
+		})
+	}
+}
+
+func TestEncodeCursorNil(t *testing.T) {
+	if token := EncodeCursor(CursorScopeSearch, nil); token != "" {
+		t.Fatalf("EncodeCursor(nil) = %q, want empty", token)
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	sentScope := CursorScope(domain.MessageStatusSent)
+	valid := EncodeCursor(sentScope, &domain.MessageCursor{ID: 1})
+
+	tests := []struct {
+		name  string
+		scope CursorScope
+		token string
+	}{
+		{name: "cursor from another listing", scope: CursorScopeSearch, token: valid},
+		{name: "not base64", scope: sentScope, token: "not a cursor!"},
+		{name: "not json", scope: sentScope, token: base64.RawURLEncoding.EncodeToString([]byte("id=1"))},
+		{name: "missing id", scope: sentScope, token: base64.RawURLEncoding.EncodeToString([]byte(`{"s":"sent"}`))},
+		{name: "negative id", scope: sentScope, token: base64.RawURLEncoding.EncodeToString([]byte(`{"s":"sent","id":-5}`))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeCursor(tt.scope, tt.token); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", tt.token, err)
+			}
+		})
+	}
+}
+
+func TestToPageRequest(t *testing.T) {
+	token := EncodeCursor(CursorScopeSearch, &domain.MessageCursor{ID: 9})
+	no := false
+
+	tests := []struct {
+		name             string
+		cursor           string
+		includeTotal     *bool
+		wantOffset       int32
+		wantIncludeTotal bool
+		wantCursor       bool
+	}{
+		{name: "offset pagination counts the total", wantOffset: 40, wantIncludeTotal: true},
+		{name: "total can be turned off", includeTotal: &no, wantOffset: 40},
+		{name: "cursor drops the offset and the total", cursor: token, wantCursor: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := toPageRequest(CursorScopeSearch, 20, 40, tt.cursor, tt.includeTotal)
+			if err != nil {
+				t.Fatalf("toPageRequest error = %v", err)
+			}
+			if page.Offset != tt.wantOffset || page.IncludeTotal != tt.wantIncludeTotal || (page.Cursor != nil) != tt.wantCursor {
+				t.Fatalf("toPageRequest = %+v, want offset %d, include total %v, cursor %v",
+					page, tt.wantOffset, tt.wantIncludeTotal, tt.wantCursor)
+			}
+		})
+	}
+}
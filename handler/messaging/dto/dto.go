@@ -1,27 +1,323 @@
 package dto
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"time"
 
 	"github.com/smitendu1997/auto-message-dispatcher/domain"
 )
 
-// Handler DTOs for List Sent Messages API
-type ListSentMessagesRequest struct {
-	Limit  int32 `json:"limit" form:"limit"`
-	Offset int32 `json:"offset" form:"offset"`
+// Handler DTOs for the list APIs, shared by every status that can be listed
+type ListMessagesRequest struct {
+	Limit        int32  `json:"limit" form:"limit"`
+	Offset       int32  `json:"offset" form:"offset"`
+	Cursor       string `json:"cursor" form:"cursor"`
+	IncludeTotal *bool  `json:"include_total" form:"include_total"`
 }
 
-// ValidateAndSetDefaults validates pagination parameters and sets defaults
-func (r *ListSentMessagesRequest) ValidateAndSetDefaults() {
+// ValidateAndSetDefaults validates pagination parameters and sets defaults for listing the given status.
+// Dead-lettered messages are always listed in bounded pages, sent and expired messages keep their
+// unbounded default unless a cursor is given.
+func (r *ListMessagesRequest) ValidateAndSetDefaults(status domain.MessageStatus) {
+	if status == domain.MessageStatusDead {
+		if r.Limit <= 0 {
+			r.Limit = defaultPageLimit
+		}
+		r.Limit = min(r.Limit, maxSearchLimit)
+	}
 	if r.Limit <= 0 {
 		r.Limit = -1 // default limit
+		if r.Cursor != "" {
+			r.Limit = defaultPageLimit // cursor pages are always bounded
+		}
 	}
 	if r.Offset < 0 {
 		r.Offset = 0
 	}
 }
 
+// ToPageRequest converts the pagination parameters to a domain page request, accepting only cursors
+// issued by the listing of the same status
+func (r *ListMessagesRequest) ToPageRequest(status domain.MessageStatus) (domain.PageRequest, error) {
+	return toPageRequest(CursorScope(status), r.Limit, r.Offset, r.Cursor, r.IncludeTotal)
+}
+
+// Handler DTOs for Create Message API
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+var ErrIdempotencyKeyMismatch = errors.New("Idempotency-Key header and client_reference must match")
+
+type CreateMessageRequest struct {
+	RecipientPhone  string     `json:"recipient_phone"`
+	Content         string     `json:"content"`
+	ClientReference string     `json:"client_reference"`
+	ScheduledAt     *time.Time `json:"scheduled_at"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+}
+
+// ApplyIdempotencyKey merges the Idempotency-Key header into the client reference
+func (r *CreateMessageRequest) ApplyIdempotencyKey(idempotencyKey string) error {
+	idempotencyKey = strings.TrimSpace(idempotencyKey)
+	if idempotencyKey == "" {
+		return nil
+	}
+	if r.ClientReference != "" && strings.TrimSpace(r.ClientReference) != idempotencyKey {
+		return ErrIdempotencyKeyMismatch
+	}
+	r.ClientReference = idempotencyKey
+	return nil
+}
+
+// ToDomain converts the create request to a domain message
+func (r *CreateMessageRequest) ToDomain() *domain.MessageDomain {
+	msg := &domain.MessageDomain{
+		RecipientPhone: strings.TrimSpace(r.RecipientPhone),
+		Content:        r.Content,
+		Status:         domain.MessageStatusPending,
+		ScheduledAt:    r.ScheduledAt,
+		ExpiresAt:      r.ExpiresAt,
+	}
+	if clientReference := strings.TrimSpace(r.ClientReference); clientReference != "" {
+		msg.ClientReference = &clientReference
+	}
+	return msg
+}
+
+type CreateMessageResponse struct {
+	ID              int64      `json:"id"`
+	Status          string     `json:"status"`
+	ClientReference *string    `json:"client_reference,omitempty"`
+	ScheduledAt     *time.Time `json:"scheduled_at,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	Replayed        bool       `json:"replayed"`
+}
+
+// ConvertCreatedMessageToResponse converts the service create result to handler response
+func ConvertCreatedMessageToResponse(created *domain.CreatedMessage) CreateMessageResponse {
+	return CreateMessageResponse{
+		ID:              created.Message.ID,
+		Status:          string(created.Message.Status),
+		ClientReference: created.Message.ClientReference,
+		ScheduledAt:     created.Message.ScheduledAt,
+		ExpiresAt:       created.Message.ExpiresAt,
+		Replayed:        created.Replayed,
+	}
+}
+
+// Handler DTOs for Bulk Create Messages API
+const (
+	BulkItemStatusAccepted = "accepted"
+	BulkItemStatusRejected = "rejected"
+)
+
+// MaxBulkItemBytes bounds a single encoded message of a bulk request, a valid message is far smaller
+// even with every character of its content escaped
+const MaxBulkItemBytes = 16 << 10
+
+var (
+	ErrBulkBatchEmpty    = errors.New("batch must contain at least one message")
+	ErrBulkBatchTooLarge = errors.New("batch exceeds the maximum allowed size")
+	ErrBulkItemTooLarge  = fmt.Errorf("a message must not exceed %d bytes", MaxBulkItemBytes)
+	ErrBulkBodyNotArray  = errors.New("body must be a JSON array of messages")
+)
+
+// MaxBulkBodyBytes is the largest bulk request body accepted for batches of up to maxBatchSize messages
+func MaxBulkBodyBytes(maxBatchSize int) int64 {
+	return int64(maxBatchSize) * MaxBulkItemBytes
+}
+
+// BulkCreateMessageItem is a single entry of a bulk request, ParseErr is set when the entry could not be decoded
+type BulkCreateMessageItem struct {
+	Request  CreateMessageRequest
+	ParseErr error
+}
+
+type BulkCreateMessageResult struct {
+	Index    int    `json:"index"`
+	Status   string `json:"status"`
+	ID       int64  `json:"id,omitempty"`
+	Replayed bool   `json:"replayed,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type BulkCreateMessagesResponse struct {
+	Accepted int                       `json:"accepted"`
+	Rejected int                       `json:"rejected"`
+	Results  []BulkCreateMessageResult `json:"results"`
+}
+
+// IsNDJSONContentType reports whether the content type denotes newline delimited JSON
+func IsNDJSONContentType(contentType string) bool {
+	switch contentType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonlines", "application/x-jsonlines":
+		return true
+	}
+	return false
+}
+
+// ParseBulkCreateMessagesJSON decodes a JSON array of messages one element at a time, rejecting
+// batches larger than maxBatchSize as soon as the extra element is reached
+func ParseBulkCreateMessagesJSON(body io.Reader, maxBatchSize int) ([]BulkCreateMessageItem, error) {
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, ErrBulkBodyNotArray
+	}
+
+	var items []BulkCreateMessageItem
+	for decoder.More() {
+		if len(items) == maxBatchSize {
+			return nil, ErrBulkBatchTooLarge
+		}
+		var entry json.RawMessage
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, err
+		}
+
+		var item BulkCreateMessageItem
+		if err := json.Unmarshal(entry, &item.Request); err != nil {
+			item.ParseErr = fmt.Errorf("invalid message: %w", err)
+		}
+		items = append(items, item)
+	}
+	// Consume the closing bracket so a truncated body is reported rather than accepted
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrBulkBatchEmpty
+	}
+	return items, nil
+}
+
+// ParseBulkCreateMessagesNDJSON decodes one message per line, skipping blank lines and
+// rejecting batches larger than maxBatchSize and lines longer than MaxBulkItemBytes
+func ParseBulkCreateMessagesNDJSON(body io.Reader, maxBatchSize int) ([]BulkCreateMessageItem, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), MaxBulkItemBytes)
+	var items []BulkCreateMessageItem
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(items) == maxBatchSize {
+			return nil, ErrBulkBatchTooLarge
+		}
+
+		var item BulkCreateMessageItem
+		if err := json.Unmarshal(line, &item.Request); err != nil {
+			item.ParseErr = fmt.Errorf("invalid message: %w", err)
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, ErrBulkItemTooLarge
+		}
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrBulkBatchEmpty
+	}
+	return items, nil
+}
+
+// Handler DTOs for Search Messages API
+const (
+	defaultPageLimit = 20
+	maxSearchLimit   = 100
+)
+
+var (
+	ErrInvalidStatusFilter = errors.New("status is not a valid message status")
+	ErrInvalidDateRange    = errors.New("from must be before to")
+)
+
+// MessageFilterRequest holds the message filters shared by the search and export APIs
+type MessageFilterRequest struct {
+	Status string     `form:"status"`
+	Phone  string     `form:"phone"`
+	From   *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To     *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Query  string     `form:"q"`
+}
+
+// Validate checks the status and date range filters
+func (r *MessageFilterRequest) Validate() error {
+	if r.Status != "" && !domain.MessageStatus(r.Status).IsValid() {
+		return ErrInvalidStatusFilter
+	}
+	if r.From != nil && r.To != nil && !r.From.Before(*r.To) {
+		return ErrInvalidDateRange
+	}
+	return nil
+}
+
+// ToDomain converts the filter parameters to a domain filter
+func (r *MessageFilterRequest) ToDomain() *domain.MessageFilter {
+	filter := &domain.MessageFilter{
+		CreatedFrom: r.From,
+		CreatedTo:   r.To,
+	}
+	if r.Status != "" {
+		status := domain.MessageStatus(r.Status)
+		filter.Status = &status
+	}
+	if phone := strings.TrimSpace(r.Phone); phone != "" {
+		filter.RecipientPhone = &phone
+	}
+	if query := strings.TrimSpace(r.Query); query != "" {
+		filter.Query = &query
+	}
+	return filter
+}
+
+type SearchMessagesRequest struct {
+	MessageFilterRequest
+	Limit  int32  `form:"limit"`
+	Offset int32  `form:"offset"`
+	Cursor string `form:"cursor"`
+	// IncludeTotal defaults to true in offset mode and false in cursor mode
+	IncludeTotal *bool `form:"include_total"`
+}
+
+// ValidateAndSetDefaults validates the filters and pagination parameters and sets defaults
+func (r *SearchMessagesRequest) ValidateAndSetDefaults() error {
+	if err := r.MessageFilterRequest.Validate(); err != nil {
+		return err
+	}
+	if r.Limit <= 0 {
+		r.Limit = defaultPageLimit
+	}
+	if r.Limit > maxSearchLimit {
+		r.Limit = maxSearchLimit
+	}
+	if r.Offset < 0 {
+		r.Offset = 0
+	}
+	return nil
+}
+
+// ToPageRequest converts the pagination parameters to a domain page request
+func (r *SearchMessagesRequest) ToPageRequest() (domain.PageRequest, error) {
+	return toPageRequest(CursorScopeSearch, r.Limit, r.Offset, r.Cursor, r.IncludeTotal)
+}
+
+type SearchMessagesResponse struct {
+	Messages   []MessageResponse  `json:"messages"`
+	Pagination PaginationMetadata `json:"pagination"`
+}
+
 type MessageResponse struct {
 	ID             int64      `json:"id"`
 	RecipientPhone string     `json:"recipient_phone"`
@@ -29,21 +325,58 @@ type MessageResponse struct {
 	Status         string     `json:"status"`
 	MessageID      *string    `json:"message_id,omitempty"`
 	SentAt         *time.Time `json:"sent_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
 	CreatedOn      *time.Time `json:"created_on,omitempty"`
 }
 
+// MessageDetailResponse is the full view of a single message including its send history
+type MessageDetailResponse struct {
+	MessageResponse
+	RetryCount      int                      `json:"retry_count"`
+	ClientReference *string                  `json:"client_reference,omitempty"`
+	ScheduledAt     *time.Time               `json:"scheduled_at,omitempty"`
+	NextAttemptAt   *time.Time               `json:"next_attempt_at,omitempty"`
+	LastError       *string                  `json:"last_error,omitempty"`
+	UpdatedOn       *time.Time               `json:"updated_on,omitempty"`
+	Attempts        []MessageAttemptResponse `json:"attempts"`
+}
+
+type MessageAttemptResponse struct {
+	ID                int64     `json:"id"`
+	Status            string    `json:"status"`
+	ProviderMessageID *string   `json:"provider_message_id,omitempty"`
+	Error             *string   `json:"error,omitempty"`
+	DurationMs        int64     `json:"duration_ms"`
+	AttemptedAt       time.Time `json:"attempted_at"`
+}
+
 type PaginationMetadata struct {
-	Limit   int32 `json:"limit"`
-	Offset  int32 `json:"offset"`
-	Total   int64 `json:"total"`
-	HasMore bool  `json:"has_more"`
+	Limit      int32  `json:"limit"`
+	Offset     int32  `json:"offset"`
+	Total      *int64 `json:"total,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
-type ListSentMessagesResponse struct {
-	Messages   []MessageResponse  `json:"messages"`
+// ListMessagesResponse is a page of a list API, Messages holds MessageResponse rows, or
+// DeadMessageResponse rows when dead-lettered messages are listed
+type ListMessagesResponse struct {
+	Messages   interface{}        `json:"messages"`
 	Pagination PaginationMetadata `json:"pagination"`
 }
 
+// NewListMessagesResponse builds the response of the list API for the given status
+func NewListMessagesResponse(status domain.MessageStatus, page domain.PageRequest, result *domain.MessagePage) ListMessagesResponse {
+	response := ListMessagesResponse{
+		Messages:   ConvertServiceResponseToHandlerResponse(result.Messages),
+		Pagination: NewPaginationMetadata(CursorScope(status), page, result),
+	}
+	if status == domain.MessageStatusDead {
+		response.Messages = ConvertDomainToDeadMessageResponses(result.Messages)
+	}
+	return response
+}
+
 // ConvertDomainToMessageResponse converts domain message to handler response
 func ConvertDomainToMessageResponse(msg *domain.MessageDomain) MessageResponse {
 	return MessageResponse{
@@ -53,6 +386,34 @@ func ConvertDomainToMessageResponse(msg *domain.MessageDomain) MessageResponse {
 		Status:         string(msg.Status),
 		MessageID:      msg.MessageID,
 		SentAt:         msg.SentAt,
+		ExpiresAt:      msg.ExpiresAt,
+		CreatedOn:      msg.CreatedOn,
+	}
+}
+
+// ConvertDomainToMessageDetailResponse converts a domain message and its attempts to handler response
+func ConvertDomainToMessageDetailResponse(msg *domain.MessageDomain, attempts []*domain.MessageAttempt) MessageDetailResponse {
+	attemptResponses := make([]MessageAttemptResponse, 0, len(attempts))
+	for _, attempt := range attempts {
+		attemptResponses = append(attemptResponses, MessageAttemptResponse{
+			ID:                attempt.ID,
+			Status:            string(attempt.Status),
+			ProviderMessageID: attempt.ProviderMessageID,
+			Error:             attempt.Error,
+			DurationMs:        attempt.Duration.Milliseconds(),
+			AttemptedAt:       attempt.AttemptedAt,
+		})
+	}
+
+	return MessageDetailResponse{
+		MessageResponse: ConvertDomainToMessageResponse(msg),
+		RetryCount:      msg.RetryCount,
+		ClientReference: msg.ClientReference,
+		ScheduledAt:     msg.ScheduledAt,
+		NextAttemptAt:   msg.NextAttemptAt,
+		LastError:       msg.LastError,
+		UpdatedOn:       msg.UpdatedOn,
+		Attempts:        attemptResponses,
 	}
 }
 
@@ -64,3 +425,56 @@ func ConvertServiceResponseToHandlerResponse(serviceResp []*domain.MessageDomain
 	}
 	return messages
 }
+
+// Handler DTOs for Dead-letter APIs
+
+// DeadMessageResponse is a dead-lettered message together with why it was given up on
+type DeadMessageResponse struct {
+	MessageResponse
+	RetryCount int        `json:"retry_count"`
+	LastError  *string    `json:"last_error,omitempty"`
+	UpdatedOn  *time.Time `json:"updated_on,omitempty"`
+}
+
+// ConvertDomainToDeadMessageResponses converts dead-lettered domain messages to handler response
+func ConvertDomainToDeadMessageResponses(msgs []*domain.MessageDomain) []DeadMessageResponse {
+	messages := make([]DeadMessageResponse, 0, len(msgs))
+	for _, msg := range msgs {
+		messages = append(messages, DeadMessageResponse{
+			MessageResponse: ConvertDomainToMessageResponse(msg),
+			RetryCount:      msg.RetryCount,
+			LastError:       msg.LastError,
+			UpdatedOn:       msg.UpdatedOn,
+		})
+	}
+	return messages
+}
+
+var ErrRequeueFilterRequired = errors.New("a phone, from, to or q filter is required, or all=true to requeue every dead-lettered message")
+
+// RequeueMessagesRequest selects the dead-lettered messages to requeue. Requeueing every one of them
+// has to be asked for explicitly with All.
+type RequeueMessagesRequest struct {
+	MessageFilterRequest
+	All bool `form:"all"`
+}
+
+// Validate checks the filters, only the dead status may be given and at least one filter is required
+// unless All is set
+func (r *RequeueMessagesRequest) Validate() error {
+	if err := r.MessageFilterRequest.Validate(); err != nil {
+		return err
+	}
+	if r.Status != "" && !domain.MessageStatus(r.Status).IsRequeueable() {
+		return domain.ErrMessageNotRequeueable
+	}
+	hasFilter := strings.TrimSpace(r.Phone) != "" || r.From != nil || r.To != nil || strings.TrimSpace(r.Query) != ""
+	if !hasFilter && !r.All {
+		return ErrRequeueFilterRequired
+	}
+	return nil
+}
+
+type RequeueMessagesResponse struct {
+	Requeued int64 `json:"requeued"`
+}
@@ -0,0 +1,87 @@
+package dto
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseBulkCreateMessages(t *testing.T) {
+	parsers := map[string]func(body string, maxBatchSize int) ([]BulkCreateMessageItem, error){
+		"json": func(body string, maxBatchSize int) ([]BulkCreateMessageItem, error) {
+			return ParseBulkCreateMessagesJSON(strings.NewReader(body), maxBatchSize)
+		},
+		"ndjson": func(body string, maxBatchSize int) ([]BulkCreateMessageItem, error) {
+			return ParseBulkCreateMessagesNDJSON(strings.NewReader(body), maxBatchSize)
+		},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		body   string
+		// wantPhones lists the recipient of each item, empty for an item that failed to parse
+		wantPhones []string
+		wantErr    error
+	}{
+		{
+			name:       "json array",
+			format:     "json",
+			body:       `[{"recipient_phone":"+905551111111","content":"a"},{"recipient_phone":"+905552222222","content":"b"}]`,
+			wantPhones: []string{"+905551111111", "+905552222222"},
+		},
+		{
+			name:       "json item that does not decode is reported on its own",
+			format:     "json",
+			body:       `[{"recipient_phone":"+905551111111","content":"a"},{"recipient_phone":5}]`,
+			wantPhones: []string{"+905551111111", ""},
+		},
+		{name: "json object", format: "json", body: `{"recipient_phone":"+905551111111"}`, wantErr: ErrBulkBodyNotArray},
+		{name: "json empty array", format: "json", body: `[]`, wantErr: ErrBulkBatchEmpty},
+		{name: "json over the batch size", format: "json", body: `[{},{},{}]`, wantErr: ErrBulkBatchTooLarge},
+		{
+			name:       "ndjson lines skipping blank ones",
+			format:     "ndjson",
+			body:       "{\"recipient_phone\":\"+905551111111\",\"content\":\"a\"}\n\n  \n{\"recipient_phone\":\"+905552222222\",\"content\":\"b\"}\n",
+			wantPhones: []string{"+905551111111", "+905552222222"},
+		},
+		{
+			name:       "ndjson line that does not decode is reported on its own",
+			format:     "ndjson",
+			body:       "not json\n{\"recipient_phone\":\"+905552222222\",\"content\":\"b\"}",
+			wantPhones: []string{"", "+905552222222"},
+		},
+		{name: "ndjson empty body", format: "ndjson", body: "\n\n", wantErr: ErrBulkBatchEmpty},
+		{name: "ndjson over the batch size", format: "ndjson", body: "{}\n{}\n{}", wantErr: ErrBulkBatchTooLarge},
+		{name: "ndjson line too long", format: "ndjson", body: `{"content":"` + strings.Repeat("x", MaxBulkItemBytes) + `"}`, wantErr: ErrBulkItemTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := parsers[tt.format](tt.body, 2)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if len(items) != len(tt.wantPhones) {
+				t.Fatalf("parsed %d items, want %d", len(items), len(tt.wantPhones))
+			}
+			for i, item := range items {
+				if tt.wantPhones[i] == "" {
+					if item.ParseErr == nil {
+						t.Fatalf("item %d parsed, want a parse error", i)
+					}
+					continue
+				}
+				if item.ParseErr != nil || item.Request.RecipientPhone != tt.wantPhones[i] {
+					t.Fatalf("item %d = %q (error %v), want %q", i, item.Request.RecipientPhone, item.ParseErr, tt.wantPhones[i])
+				}
+			}
+		})
+	}
+}
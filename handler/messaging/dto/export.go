@@ -0,0 +1,144 @@
+package dto
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Handler DTOs for Export Messages API
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+var ErrInvalidExportFormat = errors.New("format must be csv or ndjson")
+
+type ExportMessagesRequest struct {
+	MessageFilterRequest
+	Format string `form:"format"`
+}
+
+// ValidateAndSetDefaults validates the filters and export format, defaulting to CSV
+func (r *ExportMessagesRequest) ValidateAndSetDefaults() error {
+	if err := r.MessageFilterRequest.Validate(); err != nil {
+		return err
+	}
+	r.Format = strings.ToLower(strings.TrimSpace(r.Format))
+	if r.Format == "" {
+		r.Format = ExportFormatCSV
+	}
+	if r.Format != ExportFormatCSV && r.Format != ExportFormatNDJSON {
+		return ErrInvalidExportFormat
+	}
+	return nil
+}
+
+// ContentType returns the HTTP content type of the requested export format
+func (r *ExportMessagesRequest) ContentType() string {
+	if r.Format == ExportFormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// FileName returns the attachment file name for an export generated at the given time
+func (r *ExportMessagesRequest) FileName(now time.Time) string {
+	return "messages-" + now.UTC().Format("20060102T150405Z") + "." + r.Format
+}
+
+// MessageExportWriter writes exported messages one at a time. Rows are buffered
+// until Flush is called.
+type MessageExportWriter interface {
+	Write(msg MessageResponse) error
+	Flush() error
+}
+
+// NewMessageExportWriter returns a writer for the given export format
+func NewMessageExportWriter(format string, w io.Writer) (MessageExportWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return newCSVExportWriter(w)
+	case ExportFormatNDJSON:
+		buf := bufio.NewWriter(w)
+		return &ndjsonExportWriter{buf: buf, encoder: json.NewEncoder(buf)}, nil
+	default:
+		return nil, ErrInvalidExportFormat
+	}
+}
+
+// csvExportHeader lists the MessageResponse fields in column order
+var csvExportHeader = []string{"id", "recipient_phone", "content", "status", "message_id", "sent_at", "expires_at", "created_on"}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer) (*csvExportWriter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvExportHeader); err != nil {
+		return nil, err
+	}
+	return &csvExportWriter{writer: writer}, nil
+}
+
+func (w *csvExportWriter) Write(msg MessageResponse) error {
+	return w.writer.Write([]string{
+		strconv.FormatInt(msg.ID, 10),
+		msg.RecipientPhone,
+		csvEscape(msg.Content),
+		msg.Status,
+		csvString(msg.MessageID),
+		csvTime(msg.SentAt),
+		csvTime(msg.ExpiresAt),
+		csvTime(msg.CreatedOn),
+	})
+}
+
+func (w *csvExportWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// csvEscape keeps spreadsheet applications from evaluating free text as a formula by prefixing cells
+// that start with a formula trigger with a single quote. It is only applied to the content, phone
+// numbers start with + and must survive an export unchanged.
+func csvEscape(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func csvString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+type ndjsonExportWriter struct {
+	buf     *bufio.Writer
+	encoder *json.Encoder
+}
+
+// Write encodes the message as a single JSON line
+func (w *ndjsonExportWriter) Write(msg MessageResponse) error {
+	return w.encoder.Encode(msg)
+}
+
+func (w *ndjsonExportWriter) Flush() error {
+	return w.buf.Flush()
+}
@@ -0,0 +1,127 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageExportWriterCSV(t *testing.T) {
+	sentAt := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	providerID := "+provider-1"
+
+	tests := []struct {
+		name string
+		msg  MessageResponse
+		want []string
+	}{
+		{
+			name: "phone and message id are kept as they are",
+			msg:  MessageResponse{ID: 1, RecipientPhone: "+905551111111", Content: "hello", Status: "sent", MessageID: &providerID, SentAt: &sentAt},
+			want: []string{"1", "+905551111111", "hello", "sent", "+provider-1", "2025-03-01T09:30:00Z", "", ""},
+		},
+		{
+			name: "content that looks like a formula is escaped",
+			msg:  MessageResponse{ID: 2, RecipientPhone: "+905551111111", Content: "=HYPERLINK(\"x\")", Status: "pending"},
+			want: []string{"2", "+905551111111", "'=HYPERLINK(\"x\")", "pending", "", "", "", ""},
+		},
+		{
+			name: "content with separators and quotes is quoted",
+			msg:  MessageResponse{ID: 3, RecipientPhone: "+905551111111", Content: "a, \"b\"\nc", Status: "pending"},
+			want: []string{"3", "+905551111111", "a, \"b\"\nc", "pending", "", "", "", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w, err := NewMessageExportWriter(ExportFormatCSV, &out)
+			if err != nil {
+				t.Fatalf("NewMessageExportWriter error = %v", err)
+			}
+			if err := w.Write(tt.msg); err != nil {
+				t.Fatalf("Write error = %v", err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush error = %v", err)
+			}
+
+			records, err := csv.NewReader(&out).ReadAll()
+			if err != nil {
+				t.Fatalf("failed to read the export back: %v", err)
+			}
+			if len(records) != 2 || !reflect.DeepEqual(records[0], csvExportHeader) {
+				t.Fatalf("export = %q, want the header and one row", records)
+			}
+			if !reflect.DeepEqual(records[1], tt.want) {
+				t.Fatalf("row = %q, want %q", records[1], tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageExportWriterNDJSON(t *testing.T) {
+	msgs := []MessageResponse{
+		{ID: 1, RecipientPhone: "+905551111111", Content: "=1+1", Status: "sent"},
+		{ID: 2, RecipientPhone: "+905552222222", Content: "hello", Status: "pending"},
+	}
+
+	var out bytes.Buffer
+	w, err := NewMessageExportWriter(ExportFormatNDJSON, &out)
+	if err != nil {
+		t.Fatalf("NewMessageExportWriter error = %v", err)
+	}
+	for _, msg := range msgs {
+		if err := w.Write(msg); err != nil {
+			t.Fatalf("Write error = %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != len(msgs) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(msgs))
+	}
+	for i, line := range lines {
+		var got MessageResponse
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d is not JSON: %v", i+1, err)
+		}
+		if !reflect.DeepEqual(got, msgs[i]) {
+			t.Fatalf("line %d = %+v, want %+v", i+1, got, msgs[i])
+		}
+	}
+}
+
+func TestExportMessagesRequestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		wantFormat string
+		wantErr    error
+	}{
+		{name: "defaults to csv", wantFormat: ExportFormatCSV},
+		{name: "format is case insensitive", format: " NDJSON ", wantFormat: ExportFormatNDJSON},
+		{name: "unknown format", format: "xlsx", wantErr: ErrInvalidExportFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := ExportMessagesRequest{Format: tt.format}
+			err := req.ValidateAndSetDefaults()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && req.Format != tt.wantFormat {
+				t.Fatalf("format = %q, want %q", req.Format, tt.wantFormat)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package dto
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/handler/messaging/poller"
+)
+
+// Handler DTOs for Poller Config API
+const (
+	MinPollInterval    = time.Second
+	MaxPollInterval    = 24 * time.Hour
+	MaxPollBatchSize   = 1000
+	MaxDispatchWorkers = 100
+)
+
+var (
+	ErrEmptyPollerConfig   = errors.New("at least one of poll_interval, batch_size, workers or rate_limit is required")
+	ErrInvalidPollInterval = fmt.Errorf("poll_interval must be a duration between %s and %s", MinPollInterval, MaxPollInterval)
+	ErrInvalidBatchSize    = fmt.Errorf("batch_size must be between 1 and %d", MaxPollBatchSize)
+	ErrInvalidWorkers      = fmt.Errorf("workers must be between 1 and %d", MaxDispatchWorkers)
+	ErrInvalidRateLimit    = errors.New("rate_limit must not be negative")
+)
+
+// UpdatePollerConfigRequest changes only the settings that are present
+type UpdatePollerConfigRequest struct {
+	PollInterval *string  `json:"poll_interval"`
+	BatchSize    *int     `json:"batch_size"`
+	Workers      *int     `json:"workers"`
+	RateLimit    *float64 `json:"rate_limit"`
+}
+
+// Validate checks that at least one setting is given and that every given setting is in range
+func (r *UpdatePollerConfigRequest) Validate() error {
+	if r.PollInterval == nil && r.BatchSize == nil && r.Workers == nil && r.RateLimit == nil {
+		return ErrEmptyPollerConfig
+	}
+	if r.PollInterval != nil {
+		interval, err := time.ParseDuration(*r.PollInterval)
+		if err != nil || interval < MinPollInterval || interval > MaxPollInterval {
+			return ErrInvalidPollInterval
+		}
+	}
+	if r.BatchSize != nil && (*r.BatchSize < 1 || *r.BatchSize > MaxPollBatchSize) {
+		return ErrInvalidBatchSize
+	}
+	if r.Workers != nil && (*r.Workers < 1 || *r.Workers > MaxDispatchWorkers) {
+		return ErrInvalidWorkers
+	}
+	if r.RateLimit != nil && *r.RateLimit < 0 {
+		return ErrInvalidRateLimit
+	}
+	return nil
+}
+
+// ApplyTo returns the current settings with the requested changes applied, Validate must be called first
+func (r *UpdatePollerConfigRequest) ApplyTo(current poller.Settings) poller.Settings {
+	if r.PollInterval != nil {
+		current.PollInterval, _ = time.ParseDuration(*r.PollInterval)
+	}
+	if r.BatchSize != nil {
+		current.Dispatch.BatchSize = *r.BatchSize
+	}
+	if r.Workers != nil {
+		current.Dispatch.Workers = *r.Workers
+	}
+	if r.RateLimit != nil {
+		current.Dispatch.RateLimit = *r.RateLimit
+	}
+	return current
+}
+
+type PollerConfigResponse struct {
+	PollInterval string  `json:"poll_interval"`
+	BatchSize    int     `json:"batch_size"`
+	Workers      int     `json:"workers"`
+	RateLimit    float64 `json:"rate_limit"`
+}
+
+// ConvertPollerSettingsToResponse converts the poller settings to handler response
+func ConvertPollerSettingsToResponse(settings poller.Settings) PollerConfigResponse {
+	return PollerConfigResponse{
+		PollInterval: settings.PollInterval.String(),
+		BatchSize:    settings.Dispatch.BatchSize,
+		Workers:      settings.Dispatch.Workers,
+		RateLimit:    settings.Dispatch.RateLimit,
+	}
+}
+
+// Handler DTOs for Poll Now API
+type PollNowResponse struct {
+	// Coalesced is true when a poll was already pending and this request was merged into it
+	Coalesced bool `json:"coalesced"`
+	// Forwarded is true when the replica is a follower and handed the request to the leader
+	Forwarded bool `json:"forwarded"`
+}
+
+// NotLeaderResponse names the replica to send poll requests to instead
+type NotLeaderResponse struct {
+	// LeaderID is the lock owner currently polling, empty when no replica holds the lock
+	LeaderID string `json:"leader_id"`
+}
+
+// Handler DTOs for Worker Status API
+type WorkerStatusResponse struct {
+	Running    bool                   `json:"running"`
+	StartedAt  *time.Time             `json:"started_at,omitempty"`
+	NextPollAt *time.Time             `json:"next_poll_at,omitempty"`
+	LastPoll   *PollSummaryResponse   `json:"last_poll,omitempty"`
+	Totals     PollTotalsResponse     `json:"totals"`
+	Leader     *LeaderStatusResponse  `json:"leader,omitempty"`
+	RateLimit  RateLimitStatsResponse `json:"rate_limit"`
+}
+
+// RateLimitStatsResponse counts the sends let through by the outbound rate limit since the service started
+type RateLimitStatsResponse struct {
+	Backend            string  `json:"backend"`
+	Rate               float64 `json:"rate"`
+	Burst              float64 `json:"burst"`
+	Allowed            int64   `json:"allowed"`
+	Throttled          int64   `json:"throttled"`
+	ThrottledWaitMs    int64   `json:"throttled_wait_ms"`
+	MaxThrottledWaitMs int64   `json:"max_throttled_wait_ms"`
+	Fallbacks          int64   `json:"fallbacks"`
+}
+
+// LeaderStatusResponse is set when leader election is enabled
+type LeaderStatusResponse struct {
+	IsLeader bool `json:"is_leader"`
+	// LeaderID is the lock owner currently polling, the instance id and a per-process token,
+	// empty when no replica holds the lock
+	LeaderID string `json:"leader_id"`
+}
+
+// PollSummaryResponse is what a single poll did
+type PollSummaryResponse struct {
+	StartedAt           time.Time `json:"started_at"`
+	DurationMs          int64     `json:"duration_ms"`
+	Claimed             int       `json:"claimed"`
+	Sent                int       `json:"sent"`
+	Failed              int       `json:"failed"`
+	PermanentlyFailed   int       `json:"permanently_failed"`
+	Expired             int64     `json:"expired"`
+	AlreadySent         int       `json:"already_sent"`
+	Capped              int       `json:"capped"`
+	Skipped             int       `json:"skipped"`
+	RecoveredLeases     int64     `json:"recovered_leases"`
+	DeadLettered        int64     `json:"dead_lettered"`
+	MaxSendDurationMs   int64     `json:"max_send_duration_ms"`
+	TotalSendDurationMs int64     `json:"total_send_duration_ms"`
+	Error               *string   `json:"error,omitempty"`
+}
+
+// PollTotalsResponse adds up every poll since the service started
+type PollTotalsResponse struct {
+	Polls             int64 `json:"polls"`
+	FailedPolls       int64 `json:"failed_polls"`
+	Claimed           int64 `json:"claimed"`
+	Sent              int64 `json:"sent"`
+	Failed            int64 `json:"failed"`
+	PermanentlyFailed int64 `json:"permanently_failed"`
+	Expired           int64 `json:"expired"`
+	AlreadySent       int64 `json:"already_sent"`
+	Capped            int64 `json:"capped"`
+	Skipped           int64 `json:"skipped"`
+	DeadLettered      int64 `json:"dead_lettered"`
+}
+
+// ConvertPollerStatusToResponse converts the poller status to handler response
+func ConvertPollerStatusToResponse(status poller.Status) WorkerStatusResponse {
+	resp := WorkerStatusResponse{
+		Running:    status.Running,
+		StartedAt:  status.StartedAt,
+		NextPollAt: status.NextPollAt,
+		Totals: PollTotalsResponse{
+			Polls:             status.Totals.Polls,
+			FailedPolls:       status.Totals.FailedPolls,
+			Claimed:           status.Totals.Claimed,
+			Sent:              status.Totals.Sent,
+			Failed:            status.Totals.Failed,
+			PermanentlyFailed: status.Totals.PermanentlyFailed,
+			Expired:           status.Totals.Expired,
+			AlreadySent:       status.Totals.AlreadySent,
+			Capped:            status.Totals.Capped,
+			Skipped:           status.Totals.Skipped,
+			DeadLettered:      status.Totals.DeadLettered,
+		},
+	}
+	resp.RateLimit = RateLimitStatsResponse{
+		Backend:            status.RateLimit.Backend,
+		Rate:               status.RateLimit.Rate,
+		Burst:              status.RateLimit.Burst,
+		Allowed:            status.RateLimit.Allowed,
+		Throttled:          status.RateLimit.Throttled,
+		ThrottledWaitMs:    status.RateLimit.ThrottledWait.Milliseconds(),
+		MaxThrottledWaitMs: status.RateLimit.MaxThrottledWait.Milliseconds(),
+		Fallbacks:          status.RateLimit.Fallbacks,
+	}
+	if status.LeaderElection {
+		resp.Leader = &LeaderStatusResponse{IsLeader: status.IsLeader, LeaderID: status.LeaderID}
+	}
+	if last := status.LastPoll; last != nil {
+		resp.LastPoll = &PollSummaryResponse{
+			StartedAt:           last.StartedAt,
+			DurationMs:          last.Duration.Milliseconds(),
+			Claimed:             last.Claimed,
+			Sent:                last.Sent,
+			Failed:              last.Failed,
+			PermanentlyFailed:   last.PermanentlyFailed,
+			Expired:             int64(last.Expired) + last.ExpiredBeforeClaim,
+			AlreadySent:         last.AlreadySent,
+			Capped:              last.Capped,
+			Skipped:             last.Skipped,
+			RecoveredLeases:     last.RecoveredLeases,
+			DeadLettered:        last.DeadLettered,
+			MaxSendDurationMs:   last.MaxSendDuration.Milliseconds(),
+			TotalSendDurationMs: last.SendDuration.Milliseconds(),
+		}
+		if last.Err != nil {
+			errMsg := last.Err.Error()
+			resp.LastPoll.Error = &errMsg
+		}
+	}
+	return resp
+}
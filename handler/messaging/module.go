@@ -1,8 +1,13 @@
 package messaging
 
 import (
+	"context"
+	"os"
+
 	"github.com/gin-gonic/gin"
+	"github.com/smitendu1997/auto-message-dispatcher/config"
 	"github.com/smitendu1997/auto-message-dispatcher/di"
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
 	messagingGateway "github.com/smitendu1997/auto-message-dispatcher/gateway/messaging"
 	"github.com/smitendu1997/auto-message-dispatcher/handler/messaging/api"
 	"github.com/smitendu1997/auto-message-dispatcher/handler/messaging/poller"
@@ -14,6 +19,15 @@ import (
 	"github.com/smitendu1997/auto-message-dispatcher/utils"
 )
 
+const (
+	// pollerLeaderLockKey is the Redis key replicas campaign for when leader election is enabled
+	pollerLeaderLockKey = "messaging:poller:leader"
+	// pollerRequestsChannel carries poll requests from followers to the leader
+	pollerRequestsChannel = "messaging:poller:poll-now"
+	// pollerSettingsKey holds the poller settings last changed at runtime on any replica
+	pollerSettingsKey = "messaging:poller:settings"
+)
+
 // Module implements the Message poller functionality
 type Module struct {
 }
@@ -55,20 +69,74 @@ func (m *Module) Configure(container *di.Container) {
 		messagingGateway := c.Resolve((*messagingService.MessagingGateway)(nil)).(messagingService.MessagingGateway)
 		messagingRepo := c.Resolve((*messagePersistence.MessagePersistence)(nil)).(messagePersistence.MessagePersistence)
 		connections := c.Resolve((*utils.Connections)(nil)).(*utils.Connections)
-		return messagingService.NewMessagingSvc(messagingGateway, messagingRepo, connections.Redis)
+		appConfig := c.Resolve((*config.AppConfig)(nil)).(*config.AppConfig)
+		frequencyCaps, err := domain.ParseFrequencyCapRules(appConfig.Messaging.FrequencyCaps)
+		if err != nil {
+			logger.Error(functionName, "invalid MESSAGING_FREQUENCY_CAPS:", err)
+			os.Exit(1)
+		}
+		if burst := appConfig.Messaging.DispatchRateLimitBurst; burst != 0 && burst < 1 {
+			logger.Error(functionName, "MESSAGING_DISPATCH_RATE_LIMIT_BURST must be 0 or at least 1:", burst)
+			os.Exit(1)
+		}
+		frequencyCapAction := domain.FrequencyCapAction(appConfig.Messaging.FrequencyCapAction)
+		if !frequencyCapAction.IsValid() {
+			logger.Error(functionName, "MESSAGING_FREQUENCY_CAP_ACTION must be defer or reject:", frequencyCapAction)
+			os.Exit(1)
+		}
+		return messagingService.NewMessagingSvc(messagingGateway, messagingRepo, connections.Redis, messagingService.DispatchConfig{
+			BatchSize:          appConfig.Messaging.DispatchBatchSize,
+			Workers:            appConfig.Messaging.DispatchWorkers,
+			RateLimit:          appConfig.Messaging.DispatchRateLimit,
+			RateLimitBurst:     appConfig.Messaging.DispatchRateLimitBurst,
+			RateLimitFallback:  appConfig.Messaging.DispatchRateLimitFallback,
+			Replicas:           appConfig.Messaging.DispatchReplicas,
+			SendTimeout:        appConfig.Messaging.SendTimeout,
+			FrequencyCaps:      frequencyCaps,
+			FrequencyCapAction: frequencyCapAction,
+			InstanceID:         appConfig.Messaging.InstanceID,
+			LeaseDuration:      appConfig.Messaging.LeaseDuration,
+			Retry: domain.RetryPolicy{
+				BaseDelay:   appConfig.Messaging.Retry.BaseDelay,
+				Multiplier:  appConfig.Messaging.Retry.Multiplier,
+				Jitter:      appConfig.Messaging.Retry.Jitter,
+				MaxDelay:    appConfig.Messaging.Retry.MaxDelay,
+				MaxAttempts: appConfig.Messaging.Retry.MaxAttempts,
+			},
+		})
 	})
 
 	// Register Message poller
 	container.RegisterFactory((*poller.MessageHandler)(nil), func(c *di.Container) interface{} {
 		messagingService := c.Resolve((*messagingService.MessagingSvcDriver)(nil)).(messagingService.MessagingSvcDriver)
-		return poller.NewMessageHandler(messagingService)
+		appConfig := c.Resolve((*config.AppConfig)(nil)).(*config.AppConfig)
+		connections := c.Resolve((*utils.Connections)(nil)).(*utils.Connections)
+		pollerConfig := poller.Config{
+			Interval:      appConfig.Messaging.Poll.Interval,
+			Adaptive:      appConfig.Messaging.Poll.Adaptive,
+			BusyInterval:  appConfig.Messaging.Poll.BusyInterval,
+			SettingsStore: poller.NewSettingsStore(connections.Redis, pollerSettingsKey),
+		}
+		if appConfig.Messaging.Poll.LeaderElection {
+			lockTTL := appConfig.Messaging.Poll.LeaderLockTTL
+			if lockTTL <= 0 {
+				lockTTL = poller.DefaultLeaderLockTTL
+			}
+			// The random token keeps the lock owned by this process alone, even when replicas are
+			// configured with the same instance id
+			owner := appConfig.Messaging.InstanceID + ":" + utils.RandomToken()
+			pollerConfig.LeaderLock = connections.Redis.NewLock(pollerLeaderLockKey, owner, lockTTL)
+			pollerConfig.PollRequests = poller.NewPollRequests(connections.Redis, pollerRequestsChannel)
+		}
+		return poller.NewMessageHandler(messagingService, pollerConfig)
 	})
 
 	// Register API handler factory
 	container.RegisterFactory((*api.MessageAPIHandler)(nil), func(c *di.Container) interface{} {
 		messageHandler := c.Resolve((*poller.MessageHandler)(nil)).(*poller.MessageHandler)
 		messagingService := c.Resolve((*messagingService.MessagingSvcDriver)(nil)).(messagingService.MessagingSvcDriver)
-		return api.NewMessageAPIHandler(messageHandler, messagingService)
+		appConfig := c.Resolve((*config.AppConfig)(nil)).(*config.AppConfig)
+		return api.NewMessageAPIHandler(messageHandler, messagingService, appConfig.Messaging.BulkMaxBatchSize, appConfig.Messaging.Poll.OnCreate)
 	})
 
 	logger.Info(functionName, "message_api_module_configured")
@@ -92,11 +160,30 @@ func (m *Module) RegisterRoutes(router *gin.Engine, container *di.Container) {
 	{
 		actionGroup.POST("/start", handler.StartWorker())
 		actionGroup.POST("/stop", handler.StopWorker())
+		actionGroup.GET("/config", handler.GetWorkerConfig())
+		actionGroup.PATCH("/config", handler.UpdateWorkerConfig())
+		actionGroup.DELETE("/config", handler.ResetWorkerConfig())
+		actionGroup.POST("/poll-now", handler.PollNow())
+		actionGroup.GET("/status", handler.GetWorkerStatus())
+	}
+
+	messagesGroup := apiGroup.Group("/messages")
+	{
+		messagesGroup.GET("", handler.SearchMessages())
+		messagesGroup.POST("", handler.CreateMessage())
+		messagesGroup.POST("/bulk", handler.CreateMessagesBulk())
+		messagesGroup.GET("/export", handler.ExportMessages())
+		messagesGroup.POST("/requeue", handler.RequeueMessages())
+		messagesGroup.GET("/:id", handler.GetMessage())
+		messagesGroup.DELETE("/:id", handler.CancelMessage())
+		messagesGroup.POST("/:id/requeue", handler.RequeueMessage())
 	}
 
 	listGroup := apiGroup.Group("/list")
 	{
 		listGroup.GET("/sent", handler.ListSentMessages())
+		listGroup.GET("/expired", handler.ListExpiredMessages())
+		listGroup.GET("/dead", handler.ListDeadMessages())
 	}
 
 	// Auto-start the Message poller after routes are registered (all dependencies are ready)
@@ -117,3 +204,37 @@ func (m *Module) RegisterRoutes(router *gin.Engine, container *di.Container) {
 
 	logger.Info(functionName, "routes_registered")
 }
+
+// Shutdown stops the Message poller and waits for the messages it is sending until ctx is done.
+// Messages still in flight at the deadline stay claimed and are retried once their lease expires.
+func (m *Module) Shutdown(ctx context.Context, container *di.Container) error {
+	const functionName = "messaging.Module.Shutdown"
+	logger.Info(functionName, "stopping_message_poller")
+
+	messagePoller := container.Resolve((*poller.MessageHandler)(nil)).(*poller.MessageHandler)
+	if err := messagePoller.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	logger.Info(functionName, "message_poller_stopped")
+	return nil
+}
+
+// Health reports the poller state and, with leader election, which replica is polling
+func (m *Module) Health(container *di.Container) map[string]string {
+	messagePoller := container.Resolve((*poller.MessageHandler)(nil)).(*poller.MessageHandler)
+	status := messagePoller.Status()
+
+	health := map[string]string{"poller": "stopped"}
+	if status.Running {
+		health["poller"] = "running"
+	}
+	if status.LeaderElection {
+		health["poller_role"] = "follower"
+		if status.IsLeader {
+			health["poller_role"] = "leader"
+		}
+		health["poller_leader"] = status.LeaderID
+	}
+	return health
+}
@@ -0,0 +1,120 @@
+package poller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/logger"
+	"github.com/smitendu1997/auto-message-dispatcher/utils/redis"
+)
+
+// DefaultLeaderLockTTL is used when no positive lock TTL is configured
+const DefaultLeaderLockTTL = 30 * time.Second
+
+// leaderReleaseTimeout bounds giving up the lock on stop, when it fails the lock simply expires
+const leaderReleaseTimeout = 5 * time.Second
+
+// leaderElection keeps at most one replica polling at a time. Every replica campaigns for the same
+// Redis lock and only the holder polls. The holder renews the lock well within its TTL, so if it
+// dies the lock expires and the next replica to campaign takes over.
+type leaderElection struct {
+	lock *redis.Lock
+	// onElected is called when this replica becomes the leader
+	onElected func()
+
+	mu       sync.RWMutex
+	isLeader bool
+	leaderID string
+}
+
+func newLeaderElection(lock *redis.Lock, onElected func()) *leaderElection {
+	return &leaderElection{lock: lock, onElected: onElected}
+}
+
+// run campaigns for the lock until ctx is done. The lock is not released here so the caller can
+// give it up only after the poll in progress has finished.
+func (e *leaderElection) run(ctx context.Context) {
+	renewInterval := e.lock.TTL() / 3
+	e.campaign(ctx)
+
+	ticker := time.NewTicker(renewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.campaign(ctx)
+		}
+	}
+}
+
+// campaign takes or renews the lock and records who holds it. Any Redis error steps this replica
+// down, it cannot tell whether another replica has taken over.
+func (e *leaderElection) campaign(ctx context.Context) {
+	const functionName = "worker.leaderElection.campaign"
+
+	held, err := e.lock.Acquire(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error(functionName, "failed_to_campaign_for_leadership", err)
+		}
+		e.setLeader(false, "")
+		return
+	}
+	if held {
+		if e.setLeader(true, e.lock.Owner()) {
+			logger.Info(functionName, "elected_leader", e.lock.Owner())
+			e.onElected()
+		}
+		return
+	}
+
+	holder, err := e.lock.Holder(ctx)
+	if err != nil {
+		logger.Error(functionName, "failed_to_read_leader", err)
+	}
+	if e.setLeader(false, holder) {
+		logger.Info(functionName, "following_leader", holder)
+	}
+}
+
+// setLeader records the election result and reports whether this replica's role changed
+func (e *leaderElection) setLeader(isLeader bool, leaderID string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	changed := e.isLeader != isLeader
+	e.isLeader = isLeader
+	e.leaderID = leaderID
+	return changed
+}
+
+// resign gives up the lock so another replica can take over without waiting for it to expire
+func (e *leaderElection) resign() {
+	const functionName = "worker.leaderElection.resign"
+
+	e.mu.Lock()
+	wasLeader := e.isLeader
+	e.isLeader = false
+	e.leaderID = ""
+	e.mu.Unlock()
+	if !wasLeader {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), leaderReleaseTimeout)
+	defer cancel()
+	if err := e.lock.Release(ctx); err != nil {
+		logger.Error(functionName, "failed_to_release_leadership", err)
+		return
+	}
+	logger.Info(functionName, "leadership_released")
+}
+
+// status returns whether this replica leads and who the leader is, empty when unknown
+func (e *leaderElection) status() (bool, string) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.isLeader, e.leaderID
+}
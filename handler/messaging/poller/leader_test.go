@@ -0,0 +1,105 @@
+package poller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+	"github.com/smitendu1997/auto-message-dispatcher/utils/redis"
+)
+
+// testLeaderLockTTL is short so failover and renewal happen within a test
+const testLeaderLockTTL = 600 * time.Millisecond
+
+// newMiniredis runs an in-memory Redis whose clock the test moves with FastForward
+func newMiniredis(t *testing.T) (*miniredis.Miniredis, *redis.RedisClient) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client, err := redis.NewRedisClient(&redis.RedisConfig{Addresses: []string{server.Addr()}})
+	if err != nil {
+		t.Fatalf("failed to connect to miniredis: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestLeaderFailover(t *testing.T) {
+	_, client := newMiniredis(t)
+	const lockKey = "messaging:poller:leader"
+	hold := make(chan struct{})
+	close(hold)
+
+	leaderSvc := newFakeMessagingService(domain.DispatchSettings{})
+	leaderPolls := countPolls(leaderSvc, hold)
+	leader := NewMessageHandler(leaderSvc, Config{Interval: time.Hour, LeaderLock: client.NewLock(lockKey, "leader", testLeaderLockTTL)})
+	if err := leader.Start(); err != nil {
+		t.Fatalf("Start error = %v", err)
+	}
+	waitForPoll(t, leaderPolls, "the leader to poll once elected")
+
+	followerSvc := newFakeMessagingService(domain.DispatchSettings{})
+	followerPolls := countPolls(followerSvc, hold)
+	follower := NewMessageHandler(followerSvc, Config{Interval: time.Hour, LeaderLock: client.NewLock(lockKey, "follower", testLeaderLockTTL)})
+	if err := follower.Start(); err != nil {
+		t.Fatalf("Start error = %v", err)
+	}
+	defer follower.Stop()
+	expectNoPoll(t, followerPolls, "another replica leads")
+	if isLeader, leaderID := follower.Leader(); isLeader || leaderID != "leader" {
+		t.Fatalf("follower sees leader %q (is leader %v), want it following leader", leaderID, isLeader)
+	}
+
+	leader.Stop()
+	waitForPoll(t, followerPolls, "the follower to take over once the leader stopped")
+	if isLeader, leaderID := follower.Leader(); !isLeader || leaderID != "follower" {
+		t.Fatalf("follower sees leader %q (is leader %v), want it leading", leaderID, isLeader)
+	}
+}
+
+func TestLeaderRenewsWhileTheLastPollDrains(t *testing.T) {
+	server, client := newMiniredis(t)
+	const lockKey = "messaging:poller:leader"
+
+	svc := newFakeMessagingService(domain.DispatchSettings{})
+	polls := make(chan struct{}, 10)
+	release := make(chan struct{})
+	// The poll ignores cancellation like sends that are already on their way to the provider
+	svc.poll = func(ctx context.Context) domain.PollSummary {
+		polls <- struct{}{}
+		<-release
+		return domain.PollSummary{}
+	}
+	w := NewMessageHandler(svc, Config{Interval: time.Hour, LeaderLock: client.NewLock(lockKey, "leader", testLeaderLockTTL)})
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start error = %v", err)
+	}
+	waitForPoll(t, polls, "the leader to poll once elected")
+
+	stopped := make(chan struct{})
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+
+	// Let well over a TTL pass, the lock only survives if it is still being renewed
+	for i := 0; i < 4; i++ {
+		time.Sleep(testLeaderLockTTL / 2)
+		server.FastForward(testLeaderLockTTL / 2)
+	}
+	holder, err := client.NewLock(lockKey, "follower", testLeaderLockTTL).Holder(context.Background())
+	if err != nil || holder != "leader" {
+		t.Fatalf("lock holder = %q (error %v) while the last poll drains, want leader", holder, err)
+	}
+
+	close(release)
+	select {
+	case <-stopped:
+	case <-time.After(pollTimeout):
+		t.Fatal("Stop did not return once the poll finished")
+	}
+	if holder, _ := client.NewLock(lockKey, "follower", testLeaderLockTTL).Holder(context.Background()); holder != "" {
+		t.Fatalf("lock holder = %q after stop, want the lock released", holder)
+	}
+}
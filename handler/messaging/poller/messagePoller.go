@@ -2,30 +2,107 @@ package poller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
 	"github.com/smitendu1997/auto-message-dispatcher/logger"
 	messagingService "github.com/smitendu1997/auto-message-dispatcher/services/messaging"
+	"github.com/smitendu1997/auto-message-dispatcher/utils/redis"
 )
 
+// Config controls how often the poller runs. In adaptive mode a poll that claimed a full batch is
+// followed by the next one after BusyInterval instead of Interval, so backlogs drain quickly while
+// an idle queue is still only polled every Interval. With a LeaderLock only the replica holding the
+// lock polls, the others stand by to take over when it goes away, and PollRequests carries poll
+// requests made on them to the leader. With a SettingsStore settings
+// changed on one replica are picked up by all replicas with the same configuration before their next poll.
+type Config struct {
+	Interval      time.Duration
+	Adaptive      bool
+	BusyInterval  time.Duration
+	LeaderLock    *redis.Lock
+	PollRequests  *PollRequests
+	SettingsStore *SettingsStore
+}
+
+const defaultPollInterval = 120 * time.Second
+
+var (
+	ErrPollerNotRunning = errors.New("poller is not running")
+	// ErrPollerStopping is returned by Start while the poll loop of the last run is still finishing
+	ErrPollerStopping = errors.New("poller is still stopping")
+	// ErrNotLeader is returned by PollNow on a replica that does not hold the leader lock and has no
+	// PollRequests to forward the request with
+	ErrNotLeader = errors.New("this replica is not the leader")
+)
+
+// PollNowResult reports what PollNow did with a request
+type PollNowResult struct {
+	// Queued is false when a poll was already pending and the request was merged into it
+	Queued bool
+	// Forwarded is true when this replica is a follower and handed the request to the leader
+	Forwarded bool
+}
+
+// Settings are the poller and dispatch values that can be tuned while the poller is running
+type Settings struct {
+	PollInterval time.Duration
+	Dispatch     domain.DispatchSettings
+}
+
 // MessageHandler represents the Message polling worker
 type MessageHandler struct {
-	running          bool
-	stopChan         chan struct{}
-	wg               sync.WaitGroup
+	running  bool
+	stopChan chan struct{}
+	// cancelPoll cancels the context of the running poll loop so Stop does not wait for a full batch
+	cancelPoll context.CancelFunc
+	// done is closed once the poll loop of the last run has exited and leadership has been given up
+	done chan struct{}
+	// wakeChan holds at most one pending poll request so concurrent requests coalesce
+	wakeChan chan struct{}
+	// mu guards the run state only, it is never held while waiting for the poll loop
 	mu               sync.RWMutex
 	messagingService messagingService.MessagingSvcDriver
+
+	configMu sync.RWMutex
+	config   Config
+	// defaults are the configured settings, ResetSettings goes back to them
+	defaults Settings
+
+	status pollerStatus
+	// leader is nil unless leader election is enabled
+	leader *leaderElection
 }
 
 // NewMessageHandler creates a new Message Handler  instance
-func NewMessageHandler(messagingService messagingService.MessagingSvcDriver) *MessageHandler {
+func NewMessageHandler(messagingService messagingService.MessagingSvcDriver, config Config) *MessageHandler {
 	const functionName = "worker.NewMessageHandler"
 	logger.Info(functionName, "creating_message_handler")
 
+	if config.Interval <= 0 {
+		config.Interval = defaultPollInterval
+	}
+	// A busy interval longer than the idle one would slow polling down under load, cap it at the idle interval
+	config.BusyInterval = min(max(config.BusyInterval, 0), config.Interval)
+
 	worker := &MessageHandler{
 		stopChan:         make(chan struct{}),
+		done:             make(chan struct{}),
+		wakeChan:         make(chan struct{}, 1),
 		messagingService: messagingService,
+		config:           config,
+		defaults: Settings{
+			PollInterval: config.Interval,
+			Dispatch:     messagingService.DispatchSettings(),
+		},
+	}
+	close(worker.done)
+	if config.LeaderLock != nil {
+		// Poll as soon as leadership is won rather than on the follower's schedule
+		worker.leader = newLeaderElection(config.LeaderLock, func() { worker.wake() })
 	}
 
 	logger.Info(functionName, " created")
@@ -43,57 +120,297 @@ func (w *MessageHandler) Start() error {
 		logger.Info(functionName, "worker_already_running")
 		return nil
 	}
+	select {
+	case <-w.done:
+	default:
+		logger.Info(functionName, "worker_still_stopping")
+		return ErrPollerStopping
+	}
 
 	logger.Info(functionName, "starting_message_poller")
 	w.running = true
 	w.stopChan = make(chan struct{})
+	w.done = make(chan struct{})
+	// Drop a wake-up left over from before the last stop, the loop polls on start anyway
+	select {
+	case <-w.wakeChan:
+	default:
+	}
 
-	w.wg.Add(1)
-	go w.pollAndSendMessages()
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancelPoll = cancel
+	// The election has its own context so the lock is still renewed while the last poll drains
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+
+	w.status.started(time.Now())
+	var leaderWg sync.WaitGroup
+	if w.leader != nil {
+		leaderWg.Add(1)
+		go func() {
+			defer leaderWg.Done()
+			w.leader.run(leaderCtx)
+		}()
+		if w.config.PollRequests != nil {
+			leaderWg.Add(1)
+			go func() {
+				defer leaderWg.Done()
+				w.config.PollRequests.listen(leaderCtx, func() {
+					if w.isLeader() {
+						w.wake()
+					}
+				})
+			}()
+		}
+	}
+	go func(done chan struct{}) {
+		w.pollAndSendMessages(ctx, w.stopChan)
+		// Hand over leadership only once the last poll has finished
+		cancelLeader()
+		leaderWg.Wait()
+		if w.leader != nil {
+			w.leader.resign()
+		}
+		close(done)
+	}(w.done)
 
 	logger.Info(functionName, "message_poller_started")
 	return nil
 }
 
-// Stop stops the Message polling process
+// Stop stops the Message polling process and waits for the poll in progress to finish
 func (w *MessageHandler) Stop() {
 	const functionName = "worker.MessageHandler.Stop"
 
+	<-w.stop()
+	logger.Info(functionName, "message_poller_stopped")
+}
+
+// stop tells the poll loop to exit without waiting for it and returns a channel that is closed once
+// it has. The lock is released before anyone waits so PollNow and status reads are not held up.
+func (w *MessageHandler) stop() <-chan struct{} {
+	const functionName = "worker.MessageHandler.stop"
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
 	if !w.running {
 		logger.Info(functionName, "worker_not_running")
-		return
+		return w.done
 	}
 
 	logger.Info(functionName, "stopping_message_poller")
 	w.running = false
+	w.status.stopped()
 	close(w.stopChan)
-	w.wg.Wait()
-	logger.Info(functionName, "message_poller_stopped")
+	// Cancelling stops the running poll from handing out more messages, sends already in
+	// progress finish within the send timeout and are recorded before the loop exits
+	w.cancelPoll()
+	return w.done
 }
 
-// pollAndSendMessages continuously polls for messages and sends it
-func (w *MessageHandler) pollAndSendMessages() {
+// Shutdown stops the poller like Stop but only waits for the poll in progress until ctx is done
+func (w *MessageHandler) Shutdown(ctx context.Context) error {
+	const functionName = "worker.MessageHandler.Shutdown"
+
+	select {
+	case <-w.stop():
+		logger.Info(functionName, "message_poller_stopped")
+		return nil
+	case <-ctx.Done():
+		logger.Error(functionName, "gave_up_waiting_for_in_flight_messages", ctx.Err())
+		return ctx.Err()
+	}
+}
+
+// pollAndSendMessages continuously polls for messages and sends it until stopChan is closed
+func (w *MessageHandler) pollAndSendMessages(ctx context.Context, stopChan <-chan struct{}) {
 	const functionName = "worker.MessageHandler.pollAndSendMessages"
-	defer w.wg.Done()
-	ctx := context.Background()
 
 	logger.Info(functionName, "starting_message_polling")
-	w.messagingService.PollAndProcessMessages(ctx)
-	ticker := time.NewTicker(120 * time.Second)
-	defer ticker.Stop()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
 
 	for {
 		select {
-		case <-w.stopChan:
+		case <-stopChan:
 			logger.Info(functionName, "polling_stopped")
 			return
-		case <-ticker.C:
-			w.messagingService.PollAndProcessMessages(ctx)
+		case <-timer.C:
+		case <-w.wakeChan:
+			logger.Info(functionName, "immediate_poll_requested")
+			timer.Stop()
+		}
+		w.SyncSettings(ctx)
+		if !w.isLeader() {
+			interval := w.Settings().PollInterval
+			timer.Reset(interval)
+			w.status.scheduled(time.Now().Add(interval))
+			continue
+		}
+		w.status.polling()
+		summary := w.messagingService.PollAndProcessMessages(ctx)
+		delay := w.nextPollDelay(summary)
+		timer.Reset(delay)
+		w.status.polled(summary, time.Now().Add(delay))
+	}
+}
+
+// isLeader reports whether this replica may poll, always true without leader election
+func (w *MessageHandler) isLeader() bool {
+	isLeader, _ := w.Leader()
+	return isLeader
+}
+
+// Leader reports whether this replica may poll and which lock owner leads, the owner is empty when
+// unknown or when leader election is disabled
+func (w *MessageHandler) Leader() (bool, string) {
+	if w.leader == nil {
+		return true, ""
+	}
+	return w.leader.status()
+}
+
+// PollNow wakes the poller to run a poll straight away instead of waiting for the next scheduled one.
+// Requests made while a wake-up is already pending are coalesced into it. A poll in progress is not
+// interrupted, the queued poll runs right after it. Only the leader polls, a follower forwards the
+// request to it through PollRequests.
+func (w *MessageHandler) PollNow(ctx context.Context) (PollNowResult, error) {
+	w.mu.RLock()
+	running := w.running
+	w.mu.RUnlock()
+
+	if !running {
+		return PollNowResult{}, ErrPollerNotRunning
+	}
+	if w.isLeader() {
+		return PollNowResult{Queued: w.wake()}, nil
+	}
+	if w.config.PollRequests == nil {
+		return PollNowResult{}, ErrNotLeader
+	}
+	if err := w.config.PollRequests.forward(ctx); err != nil {
+		return PollNowResult{}, fmt.Errorf("%w: %v", ErrPollNotForwarded, err)
+	}
+	return PollNowResult{Queued: true, Forwarded: true}, nil
+}
+
+// wake queues a poll unless one is already pending and reports whether it did
+func (w *MessageHandler) wake() bool {
+	select {
+	case w.wakeChan <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// nextPollDelay decides when to poll again. A full batch means the queue likely has a backlog so in
+// adaptive mode the next poll follows right away, otherwise the poller waits the idle interval.
+func (w *MessageHandler) nextPollDelay(summary domain.PollSummary) time.Duration {
+	w.configMu.RLock()
+	defer w.configMu.RUnlock()
+	if w.config.Adaptive && summary.IsFullBatch() {
+		// The interval may have been lowered below the busy interval at runtime
+		return min(w.config.BusyInterval, w.config.Interval)
+	}
+	return w.config.Interval
+}
+
+// Settings returns the poll interval and dispatch settings currently in effect
+func (w *MessageHandler) Settings() Settings {
+	w.configMu.RLock()
+	defer w.configMu.RUnlock()
+	return Settings{
+		PollInterval: w.config.Interval,
+		Dispatch:     w.messagingService.DispatchSettings(),
+	}
+}
+
+// UpdateSettings changes the poll interval and dispatch settings without restarting the poller and
+// shares them with the other replicas. The wait already scheduled is not cut short, the new interval
+// is used from the next poll on. It returns the settings now in effect, or an error and no change
+// when the dispatch settings are rejected or could not be shared.
+func (w *MessageHandler) UpdateSettings(ctx context.Context, settings Settings) (Settings, error) {
+	const functionName = "worker.MessageHandler.UpdateSettings"
+
+	previous := w.Settings()
+	applied, err := w.applySettings(settings)
+	if err != nil || w.config.SettingsStore == nil {
+		return applied, err
+	}
+	if err := w.config.SettingsStore.save(ctx, applied, w.defaults); err != nil {
+		logger.Error(functionName, "failed_to_share_poller_settings", err)
+		if _, revertErr := w.applySettings(previous); revertErr != nil {
+			logger.Error(functionName, "failed_to_restore_poller_settings", revertErr)
+		}
+		return Settings{}, fmt.Errorf("%w: %v", ErrSettingsNotShared, err)
+	}
+	return applied, nil
+}
+
+// ResetSettings drops the settings changed at runtime on every replica and goes back to the
+// configured ones. It returns the settings now in effect, or ErrSettingsNotShared and no change
+// when the shared settings could not be removed.
+func (w *MessageHandler) ResetSettings(ctx context.Context) (Settings, error) {
+	const functionName = "worker.MessageHandler.ResetSettings"
+
+	if w.config.SettingsStore != nil {
+		if err := w.config.SettingsStore.clear(ctx); err != nil {
+			logger.Error(functionName, "failed_to_clear_shared_settings", err)
+			return Settings{}, fmt.Errorf("%w: %v", ErrSettingsNotShared, err)
 		}
 	}
+	return w.applySettings(w.defaults)
+}
+
+// SyncSettings applies the settings last changed on any replica with the same configuration, or
+// the configured settings when there are none, if they differ from the ones in effect here.
+// Errors are logged and the current settings kept.
+func (w *MessageHandler) SyncSettings(ctx context.Context) {
+	const functionName = "worker.MessageHandler.SyncSettings"
+
+	if w.config.SettingsStore == nil {
+		return
+	}
+	shared, found, err := w.config.SettingsStore.load(ctx, w.defaults)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error(functionName, "failed_to_load_shared_settings", err)
+		}
+		return
+	}
+	if !found {
+		shared = w.defaults
+	}
+	if shared == w.Settings() {
+		return
+	}
+	if _, err := w.applySettings(shared); err != nil {
+		logger.Error(functionName, "shared_settings_rejected", err)
+	}
+}
+
+// applySettings changes the settings on this replica only
+func (w *MessageHandler) applySettings(settings Settings) (Settings, error) {
+	const functionName = "worker.MessageHandler.applySettings"
+
+	w.configMu.Lock()
+	defer w.configMu.Unlock()
+
+	dispatch, err := w.messagingService.UpdateDispatchSettings(settings.Dispatch)
+	if err != nil {
+		logger.Error(functionName, "invalid_dispatch_settings", err)
+		return Settings{}, err
+	}
+	if settings.PollInterval > 0 {
+		w.config.Interval = settings.PollInterval
+	}
+	logger.Info(functionName, "poller_settings_updated", "interval", w.config.Interval.String())
+
+	return Settings{
+		PollInterval: w.config.Interval,
+		Dispatch:     dispatch,
+	}, nil
 }
 
 // IsRunning checks if the Message polling worker is running
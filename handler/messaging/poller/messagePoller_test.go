@@ -0,0 +1,241 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+)
+
+// pollTimeout bounds how long a test waits for a poll it expects
+const pollTimeout = 2 * time.Second
+
+// countPolls makes every poll of svc signal polls, blocking while hold is open
+func countPolls(svc *fakeMessagingService, hold <-chan struct{}) <-chan struct{} {
+	polls := make(chan struct{}, 100)
+	svc.poll = func(ctx context.Context) domain.PollSummary {
+		polls <- struct{}{}
+		select {
+		case <-hold:
+		case <-ctx.Done():
+		}
+		return domain.PollSummary{}
+	}
+	return polls
+}
+
+func waitForPoll(t *testing.T, polls <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-polls:
+	case <-time.After(pollTimeout):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func expectNoPoll(t *testing.T, polls <-chan struct{}, why string) {
+	t.Helper()
+	select {
+	case <-polls:
+		t.Fatalf("polled although %s", why)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPollNowNotRunning(t *testing.T) {
+	w := NewMessageHandler(newFakeMessagingService(domain.DispatchSettings{}), Config{Interval: time.Hour})
+	if _, err := w.PollNow(context.Background()); !errors.Is(err, ErrPollerNotRunning) {
+		t.Fatalf("PollNow error = %v, want ErrPollerNotRunning", err)
+	}
+}
+
+func TestPollNowCoalesces(t *testing.T) {
+	svc := newFakeMessagingService(domain.DispatchSettings{})
+	hold := make(chan struct{})
+	polls := countPolls(svc, hold)
+	w := NewMessageHandler(svc, Config{Interval: time.Hour})
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start error = %v", err)
+	}
+	defer w.Stop()
+	waitForPoll(t, polls, "the poll on start")
+
+	// The first poll is still running, both requests wait for it and share one poll
+	for i, wantQueued := range []bool{true, false} {
+		result, err := w.PollNow(context.Background())
+		if err != nil {
+			t.Fatalf("PollNow %d error = %v", i+1, err)
+		}
+		if result.Queued != wantQueued || result.Forwarded {
+			t.Fatalf("PollNow %d = %+v, want queued %v and not forwarded", i+1, result, wantQueued)
+		}
+	}
+	close(hold)
+	waitForPoll(t, polls, "the requested poll")
+	expectNoPoll(t, polls, "the second request was coalesced")
+}
+
+func TestPollNowForwardsToTheLeader(t *testing.T) {
+	client := newTestRedis(t)
+	lockKey := testKey(t, client, "messaging:poller:leader:")
+	requests := NewPollRequests(client, "messaging:poller:poll-now:test:"+t.Name())
+	hold := make(chan struct{})
+	close(hold)
+
+	leaderSvc := newFakeMessagingService(domain.DispatchSettings{})
+	leaderPolls := countPolls(leaderSvc, hold)
+	leader := NewMessageHandler(leaderSvc, Config{Interval: time.Hour, LeaderLock: client.NewLock(lockKey, "leader", 3*time.Second), PollRequests: requests})
+	if err := leader.Start(); err != nil {
+		t.Fatalf("Start error = %v", err)
+	}
+	defer leader.Stop()
+	waitForPoll(t, leaderPolls, "the leader to poll once elected")
+
+	followerSvc := newFakeMessagingService(domain.DispatchSettings{})
+	followerPolls := countPolls(followerSvc, hold)
+	follower := NewMessageHandler(followerSvc, Config{Interval: time.Hour, LeaderLock: client.NewLock(lockKey, "follower", 3*time.Second), PollRequests: requests})
+	if err := follower.Start(); err != nil {
+		t.Fatalf("Start error = %v", err)
+	}
+	defer follower.Stop()
+
+	// The leader may not have subscribed yet, keep asking until it polls
+	deadline := time.After(pollTimeout)
+	for polled := false; !polled; {
+		result, err := follower.PollNow(context.Background())
+		if err != nil {
+			t.Fatalf("PollNow error = %v", err)
+		}
+		if !result.Forwarded {
+			t.Fatalf("PollNow = %+v on the follower, want it forwarded", result)
+		}
+		select {
+		case <-leaderPolls:
+			polled = true
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("timed out waiting for the leader to poll")
+		}
+	}
+	expectNoPoll(t, followerPolls, "this replica is a follower")
+}
+
+func TestStatus(t *testing.T) {
+	svc := newFakeMessagingService(domain.DispatchSettings{})
+	polled := make(chan struct{}, 10)
+	svc.poll = func(ctx context.Context) domain.PollSummary {
+		defer func() { polled <- struct{}{} }()
+		return domain.PollSummary{BatchSize: 10, Claimed: 3, Sent: 2, Failed: 1}
+	}
+	w := NewMessageHandler(svc, Config{Interval: time.Hour})
+
+	if status := w.Status(); status.Running || status.LastPoll != nil || status.LeaderElection {
+		t.Fatalf("status before start = %+v, want stopped without polls", status)
+	}
+
+	started := time.Now()
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start error = %v", err)
+	}
+	waitForPoll(t, polled, "the poll on start")
+	// The status is recorded right after the poll returns
+	deadline := time.Now().Add(pollTimeout)
+	for w.Status().NextPollAt == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	status := w.Status()
+	if !status.Running || status.StartedAt == nil || status.StartedAt.Before(started) {
+		t.Fatalf("status = %+v, want running since the start", status)
+	}
+	if status.LastPoll == nil || status.LastPoll.Sent != 2 || status.LastPoll.Failed != 1 {
+		t.Fatalf("last poll = %+v, want the summary of the poll", status.LastPoll)
+	}
+	if status.Totals.Polls != 1 || status.Totals.Claimed != 3 {
+		t.Fatalf("totals = %+v, want one poll claiming 3", status.Totals)
+	}
+	if status.NextPollAt == nil || status.NextPollAt.Before(started.Add(time.Hour)) {
+		t.Fatalf("next poll at %v, want an interval after the poll", status.NextPollAt)
+	}
+	if status.RateLimit.Backend != "local" {
+		t.Fatalf("rate limit = %+v, want the service's stats", status.RateLimit)
+	}
+
+	w.Stop()
+	status = w.Status()
+	if status.Running || status.StartedAt != nil || status.NextPollAt != nil {
+		t.Fatalf("status after stop = %+v, want stopped with nothing scheduled", status)
+	}
+	if status.LastPoll == nil || status.Totals.Polls != 1 {
+		t.Fatalf("status after stop = %+v, want the last poll and totals kept", status)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	svc := newFakeMessagingService(domain.DispatchSettings{})
+	polls := make(chan struct{}, 10)
+	release := make(chan struct{})
+	finished := make(chan struct{}, 10)
+	// The poll ignores cancellation like a send that is already on its way to the provider
+	svc.poll = func(ctx context.Context) domain.PollSummary {
+		polls <- struct{}{}
+		<-release
+		finished <- struct{}{}
+		return domain.PollSummary{}
+	}
+	w := NewMessageHandler(svc, Config{Interval: time.Hour})
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start error = %v", err)
+	}
+	waitForPoll(t, polls, "the poll on start")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := w.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown error = %v, want the deadline exceeded while the poll is in flight", err)
+	}
+	if w.IsRunning() {
+		t.Fatal("poller is running after Shutdown")
+	}
+	if err := w.Start(); !errors.Is(err, ErrPollerStopping) {
+		t.Fatalf("Start error = %v, want ErrPollerStopping while the last poll drains", err)
+	}
+
+	close(release)
+	if err := w.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown error = %v, want nil once the poll has drained", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Fatal("Shutdown returned before the in-flight poll finished")
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start error = %v after the poll drained", err)
+	}
+	w.Stop()
+}
+
+func TestStopCancelsThePoll(t *testing.T) {
+	svc := newFakeMessagingService(domain.DispatchSettings{})
+	// The hold is never released, the poll only returns once its context is cancelled
+	polls := countPolls(svc, make(chan struct{}))
+	w := NewMessageHandler(svc, Config{Interval: time.Hour})
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start error = %v", err)
+	}
+	waitForPoll(t, polls, "the poll on start")
+
+	stopped := make(chan struct{})
+	go func() {
+		w.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(pollTimeout):
+		t.Fatal("Stop did not cancel the hung poll")
+	}
+}
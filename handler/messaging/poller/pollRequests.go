@@ -0,0 +1,56 @@
+package poller
+
+import (
+	"context"
+	"errors"
+
+	"github.com/smitendu1997/auto-message-dispatcher/logger"
+	"github.com/smitendu1997/auto-message-dispatcher/utils/redis"
+)
+
+// ErrPollNotForwarded is returned by PollNow on a follower when the request could not be handed to the leader
+var ErrPollNotForwarded = errors.New("poll request could not be forwarded to the leader")
+
+// PollRequests carries poll requests made on followers to the leader over Redis pub/sub, so
+// PollNow works on whichever replica it is called on. A request published while no replica leads
+// is lost, the next leader polls as soon as it is elected anyway.
+type PollRequests struct {
+	client  *redis.RedisClient
+	channel string
+}
+
+// NewPollRequests returns a relay for poll requests on channel
+func NewPollRequests(client *redis.RedisClient, channel string) *PollRequests {
+	return &PollRequests{client: client, channel: channel}
+}
+
+// forward asks the leader to poll
+func (r *PollRequests) forward(ctx context.Context) error {
+	return r.client.Publish(ctx, r.channel, "poll")
+}
+
+// listen calls onRequest for every forwarded request until ctx is done
+func (r *PollRequests) listen(ctx context.Context, onRequest func()) {
+	const functionName = "worker.PollRequests.listen"
+
+	subscription := r.client.Subscribe(ctx, r.channel)
+	defer subscription.Close()
+	// Receive waits for the subscription to be confirmed so requests sent after Start are not missed
+	if _, err := subscription.Receive(ctx); err != nil {
+		if ctx.Err() == nil {
+			logger.Error(functionName, "failed_to_subscribe_to_poll_requests", err)
+		}
+	}
+	requests := subscription.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-requests:
+			if !ok {
+				return
+			}
+			onRequest()
+		}
+	}
+}
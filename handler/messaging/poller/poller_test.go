@@ -0,0 +1,84 @@
+package poller
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+	messagingService "github.com/smitendu1997/auto-message-dispatcher/services/messaging"
+	"github.com/smitendu1997/auto-message-dispatcher/utils/redis"
+)
+
+// fakeMessagingService keeps the dispatch settings in memory and hands each poll to the poll
+// callback. Other calls are not implemented and panic through the embedded nil interface.
+type fakeMessagingService struct {
+	messagingService.MessagingSvcDriver
+
+	mu       sync.Mutex
+	settings domain.DispatchSettings
+	poll     func(ctx context.Context) domain.PollSummary
+}
+
+func newFakeMessagingService(settings domain.DispatchSettings) *fakeMessagingService {
+	return &fakeMessagingService{settings: settings}
+}
+
+func (s *fakeMessagingService) DispatchSettings() domain.DispatchSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings
+}
+
+func (s *fakeMessagingService) UpdateDispatchSettings(settings domain.DispatchSettings) (domain.DispatchSettings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if settings.BatchSize <= 0 {
+		settings.BatchSize = s.settings.BatchSize
+	}
+	if settings.Workers <= 0 {
+		settings.Workers = s.settings.Workers
+	}
+	s.settings = settings
+	return settings, nil
+}
+
+func (s *fakeMessagingService) RateLimitStats() domain.RateLimitStats {
+	return domain.RateLimitStats{Backend: "local"}
+}
+
+func (s *fakeMessagingService) PollAndProcessMessages(ctx context.Context) domain.PollSummary {
+	if s.poll == nil {
+		return domain.PollSummary{}
+	}
+	return s.poll(ctx)
+}
+
+// newTestRedis connects to the Redis at TEST_REDIS_ADDR when it is set and to an in-memory
+// miniredis otherwise
+func newTestRedis(t *testing.T) *redis.RedisClient {
+	t.Helper()
+
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		server := miniredis.RunT(t)
+		addr = server.Addr()
+	}
+	client, err := redis.NewRedisClient(&redis.RedisConfig{Addresses: []string{addr}})
+	if err != nil {
+		t.Fatalf("failed to connect to redis at %s: %v", addr, err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// testKey returns a key no other test uses, and removes it once the test is done
+func testKey(t *testing.T, client *redis.RedisClient, prefix string) string {
+	t.Helper()
+	key := prefix + "test:" + t.Name()
+	client.Del(context.Background(), key)
+	t.Cleanup(func() { client.Del(context.Background(), key) })
+	return key
+}
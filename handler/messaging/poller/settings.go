@@ -0,0 +1,102 @@
+package poller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	redisGo "github.com/redis/go-redis/v9"
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+	"github.com/smitendu1997/auto-message-dispatcher/utils/redis"
+)
+
+// ErrSettingsNotShared is returned when changed settings could not be saved for the other replicas,
+// the change is then undone on this replica as well
+var ErrSettingsNotShared = errors.New("settings could not be shared with the other replicas")
+
+// SettingsStore keeps the settings changed at runtime in Redis so every replica polls with the same
+// values, whichever replica the change was made on. A change is saved together with the configured
+// settings it was made on top of. Replicas configured differently, e.g. after a redeploy with new
+// values, ignore it and run with their own configuration, so a redeploy is never overridden by a
+// change made before it.
+type SettingsStore struct {
+	client *redis.RedisClient
+	key    string
+}
+
+// NewSettingsStore returns a store for the settings under key
+func NewSettingsStore(client *redis.RedisClient, key string) *SettingsStore {
+	return &SettingsStore{client: client, key: key}
+}
+
+// storedValues is how Settings are written to Redis
+type storedValues struct {
+	PollInterval string  `json:"poll_interval"`
+	BatchSize    int     `json:"batch_size"`
+	Workers      int     `json:"workers"`
+	RateLimit    float64 `json:"rate_limit"`
+}
+
+type storedSettings struct {
+	storedValues
+	// Defaults are the configured settings of the replica the change was made on
+	Defaults storedValues `json:"defaults"`
+}
+
+func toStoredValues(settings Settings) storedValues {
+	return storedValues{
+		PollInterval: settings.PollInterval.String(),
+		BatchSize:    settings.Dispatch.BatchSize,
+		Workers:      settings.Dispatch.Workers,
+		RateLimit:    settings.Dispatch.RateLimit,
+	}
+}
+
+// save writes settings for the other replicas with the same defaults to pick up
+func (s *SettingsStore) save(ctx context.Context, settings, defaults Settings) error {
+	payload, err := json.Marshal(storedSettings{
+		storedValues: toStoredValues(settings),
+		Defaults:     toStoredValues(defaults),
+	})
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, s.key, payload, 0)
+}
+
+// load reads the shared settings, the bool is false when none have been saved on top of defaults
+func (s *SettingsStore) load(ctx context.Context, defaults Settings) (Settings, bool, error) {
+	payload, err := s.client.Get(ctx, s.key)
+	if errors.Is(err, redisGo.Nil) {
+		return Settings{}, false, nil
+	}
+	if err != nil {
+		return Settings{}, false, err
+	}
+
+	var stored storedSettings
+	if err := json.Unmarshal([]byte(payload), &stored); err != nil {
+		return Settings{}, false, err
+	}
+	if stored.Defaults != toStoredValues(defaults) {
+		return Settings{}, false, nil
+	}
+	interval, err := time.ParseDuration(stored.PollInterval)
+	if err != nil {
+		return Settings{}, false, err
+	}
+	return Settings{
+		PollInterval: interval,
+		Dispatch: domain.DispatchSettings{
+			BatchSize: stored.BatchSize,
+			Workers:   stored.Workers,
+			RateLimit: stored.RateLimit,
+		},
+	}, true, nil
+}
+
+// clear removes the shared settings so every replica goes back to its configuration
+func (s *SettingsStore) clear(ctx context.Context) error {
+	return s.client.Del(ctx, s.key)
+}
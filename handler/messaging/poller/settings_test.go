@@ -0,0 +1,74 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+)
+
+func TestSharedSettings(t *testing.T) {
+	configured := domain.DispatchSettings{BatchSize: 100, Workers: 10, RateLimit: 5}
+	redeployed := domain.DispatchSettings{BatchSize: 200, Workers: 10, RateLimit: 5}
+	changed := Settings{PollInterval: time.Minute, Dispatch: domain.DispatchSettings{BatchSize: 50, Workers: 5, RateLimit: 2}}
+
+	tests := []struct {
+		name string
+		// other is the configuration of the replica that syncs after the change
+		other domain.DispatchSettings
+		reset bool
+		want  func(defaults Settings) Settings
+	}{
+		{name: "a replica with the same configuration picks the change up", other: configured, want: func(Settings) Settings { return changed }},
+		{name: "a redeployed replica keeps its own configuration", other: redeployed, want: func(defaults Settings) Settings { return defaults }},
+		{name: "a reset goes back to the configuration", other: configured, reset: true, want: func(defaults Settings) Settings { return defaults }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestRedis(t)
+			store := NewSettingsStore(client, testKey(t, client, "messaging:poller:settings:"))
+			ctx := context.Background()
+
+			changing := NewMessageHandler(newFakeMessagingService(configured), Config{Interval: 2 * time.Minute, SettingsStore: store})
+			other := NewMessageHandler(newFakeMessagingService(tt.other), Config{Interval: 2 * time.Minute, SettingsStore: store})
+			// The other replica has seen the change before it is reset
+			other.SyncSettings(ctx)
+
+			if _, err := changing.UpdateSettings(ctx, changed); err != nil {
+				t.Fatalf("UpdateSettings error = %v", err)
+			}
+			other.SyncSettings(ctx)
+			if tt.reset {
+				if _, err := changing.ResetSettings(ctx); err != nil {
+					t.Fatalf("ResetSettings error = %v", err)
+				}
+				if got := changing.Settings(); got != changing.defaults {
+					t.Fatalf("settings after reset = %+v, want %+v", got, changing.defaults)
+				}
+				other.SyncSettings(ctx)
+			}
+
+			if got, want := other.Settings(), tt.want(other.defaults); got != want {
+				t.Fatalf("settings = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestUpdateSettingsWithoutRedis(t *testing.T) {
+	client := newTestRedis(t)
+	store := NewSettingsStore(client, "unused")
+	client.Close()
+
+	w := NewMessageHandler(newFakeMessagingService(domain.DispatchSettings{BatchSize: 100, Workers: 10}), Config{Interval: time.Minute, SettingsStore: store})
+	before := w.Settings()
+	if _, err := w.UpdateSettings(context.Background(), Settings{PollInterval: time.Second}); !errors.Is(err, ErrSettingsNotShared) {
+		t.Fatalf("UpdateSettings error = %v, want ErrSettingsNotShared", err)
+	}
+	if got := w.Settings(); got != before {
+		t.Fatalf("settings = %+v after a failed update, want %+v", got, before)
+	}
+}
@@ -0,0 +1,92 @@
+package poller
+
+import (
+	"sync"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+)
+
+// Status is a snapshot of what the poller is doing
+type Status struct {
+	Running bool
+	// StartedAt is when the poller was last started, nil while it is stopped
+	StartedAt *time.Time
+	// LastPoll is the summary of the most recent poll, nil before the first one
+	LastPoll *domain.PollSummary
+	// NextPollAt is when the next poll is scheduled, nil while it is stopped or a poll is running
+	NextPollAt *time.Time
+	// Totals adds up every poll since the service started
+	Totals domain.PollTotals
+	// LeaderElection is set when only the replica holding the leader lock polls
+	LeaderElection bool
+	// IsLeader reports whether this replica holds the leader lock, LeaderID is the instance that does
+	IsLeader bool
+	LeaderID string
+	// RateLimit describes the outbound rate limit and how often sends waited for it
+	RateLimit domain.RateLimitStats
+}
+
+// pollerStatus records the poll loop's progress. It has its own lock so the poll loop can update it
+// without taking the handler lock.
+type pollerStatus struct {
+	mu     sync.RWMutex
+	status Status
+}
+
+func (s *pollerStatus) started(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = true
+	s.status.StartedAt = &now
+	s.status.NextPollAt = nil
+}
+
+func (s *pollerStatus) stopped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = false
+	s.status.StartedAt = nil
+	s.status.NextPollAt = nil
+}
+
+func (s *pollerStatus) polling() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.NextPollAt = nil
+}
+
+func (s *pollerStatus) polled(summary domain.PollSummary, nextPollAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastPoll = &summary
+	s.status.Totals.Add(summary)
+	if s.status.Running {
+		s.status.NextPollAt = &nextPollAt
+	}
+}
+
+func (s *pollerStatus) scheduled(nextPollAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status.Running {
+		s.status.NextPollAt = &nextPollAt
+	}
+}
+
+func (s *pollerStatus) snapshot() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// Status returns the running state, the last poll, the totals and the leader of the poller
+func (w *MessageHandler) Status() Status {
+	status := w.status.snapshot()
+	status.RateLimit = w.messagingService.RateLimitStats()
+	if w.leader != nil {
+		status.LeaderElection = true
+		status.IsLeader, status.LeaderID = w.leader.status()
+	}
+	return status
+}
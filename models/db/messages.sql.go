@@ -8,16 +8,543 @@ package db
 import (
 	"context"
 	"database/sql"
+	"strings"
+	"time"
 )
 
+const cancelMessage = `-- name: CancelMessage :execrows
+UPDATE messages
+SET status = 'cancelled'
+WHERE id = ?
+AND status in ('pending','failed')
+`
+
+func (q *Queries) CancelMessage(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, cancelMessage, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const claimMessages = `-- name: ClaimMessages :execrows
+UPDATE messages
+SET status = 'processing', lease_owner = ?, lease_expires_at = ?
+WHERE id IN (/*SLICE:ids*/?)
+`
+
+type ClaimMessagesParams struct {
+	LeaseOwner     sql.NullString
+	LeaseExpiresAt sql.NullTime
+	Ids            []int64
+}
+
+func (q *Queries) ClaimMessages(ctx context.Context, arg ClaimMessagesParams) (int64, error) {
+	query := claimMessages
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.LeaseOwner)
+	queryParams = append(queryParams, arg.LeaseExpiresAt)
+	if len(arg.Ids) > 0 {
+		for _, v := range arg.Ids {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:ids*/?", strings.Repeat(",?", len(arg.Ids))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:ids*/?", "NULL", 1)
+	}
+	result, err := q.db.ExecContext(ctx, query, queryParams...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createMessage = `-- name: CreateMessage :execlastid
+INSERT INTO messages (recipient_phone, content, status, client_reference, scheduled_at, expires_at)
+VALUES (?, ?, 'pending', ?, ?, ?)
+`
+
+type CreateMessageParams struct {
+	RecipientPhone  string
+	Content         string
+	ClientReference sql.NullString
+	ScheduledAt     sql.NullTime
+	ExpiresAt       sql.NullTime
+}
+
+func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createMessage,
+		arg.RecipientPhone,
+		arg.Content,
+		arg.ClientReference,
+		arg.ScheduledAt,
+		arg.ExpiresAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+const createMessageAttempt = `-- name: CreateMessageAttempt :exec
+INSERT INTO message_attempts (message_id, status, provider_message_id, error, duration_ms, attempted_at)
+VALUES (?, ?, ?, ?, ?, ?)
+`
+
+type CreateMessageAttemptParams struct {
+	MessageID         int64
+	Status            MessageAttemptsStatus
+	ProviderMessageID sql.NullString
+	Error             sql.NullString
+	DurationMs        int32
+	AttemptedAt       time.Time
+}
+
+func (q *Queries) CreateMessageAttempt(ctx context.Context, arg CreateMessageAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, createMessageAttempt,
+		arg.MessageID,
+		arg.Status,
+		arg.ProviderMessageID,
+		arg.Error,
+		arg.DurationMs,
+		arg.AttemptedAt,
+	)
+	return err
+}
+
+const deadLetterExhaustedMessages = `-- name: DeadLetterExhaustedMessages :execrows
+UPDATE messages
+SET status = 'dead', next_attempt_at = NULL
+WHERE status = 'failed'
+AND retry_count >= ?
+`
+
+func (q *Queries) DeadLetterExhaustedMessages(ctx context.Context, maxAttempts int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deadLetterExhaustedMessages, maxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deferMessage = `-- name: DeferMessage :execrows
+UPDATE messages
+SET status = CASE WHEN retry_count > 0 THEN 'failed' ELSE 'pending' END,
+    next_attempt_at = ?,
+    last_error = ?,
+    lease_owner = NULL,
+    lease_expires_at = NULL
+WHERE id = ?
+AND status = 'processing'
+AND lease_owner = ?
+`
+
+type DeferMessageParams struct {
+	NextAttemptAt sql.NullTime
+	LastError     sql.NullString
+	ID            int64
+	LeaseOwner    sql.NullString
+}
+
+func (q *Queries) DeferMessage(ctx context.Context, arg DeferMessageParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deferMessage,
+		arg.NextAttemptAt,
+		arg.LastError,
+		arg.ID,
+		arg.LeaseOwner,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const expireMessages = `-- name: ExpireMessages :execrows
+UPDATE messages
+SET status = 'expired'
+WHERE status in ('pending','failed')
+AND expires_at IS NOT NULL
+AND expires_at <= ?
+`
+
+func (q *Queries) ExpireMessages(ctx context.Context, now sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, expireMessages, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getExpiredMessages = `-- name: GetExpiredMessages :many
+SELECT id, recipient_phone, content, status, messageid, sent_at, createdon, retry_count, expires_at FROM messages
+WHERE status = 'expired'
+ORDER BY expires_at DESC, id DESC
+LIMIT ? OFFSET ?
+`
+
+type GetExpiredMessagesParams struct {
+	Limit  int32
+	Offset int32
+}
+
+type GetExpiredMessagesRow struct {
+	ID             int64
+	RecipientPhone string
+	Content        string
+	Status         MessagesStatus
+	Messageid      sql.NullString
+	SentAt         sql.NullTime
+	Createdon      sql.NullTime
+	RetryCount     int32
+	ExpiresAt      sql.NullTime
+}
+
+func (q *Queries) GetExpiredMessages(ctx context.Context, arg GetExpiredMessagesParams) ([]GetExpiredMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getExpiredMessages, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetExpiredMessagesRow
+	for rows.Next() {
+		var i GetExpiredMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RecipientPhone,
+			&i.Content,
+			&i.Status,
+			&i.Messageid,
+			&i.SentAt,
+			&i.Createdon,
+			&i.RetryCount,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getExpiredMessagesAfterCursor = `-- name: GetExpiredMessagesAfterCursor :many
+SELECT id, recipient_phone, content, status, messageid, sent_at, createdon, retry_count, expires_at FROM messages
+WHERE status = 'expired'
+AND (
+    expires_at < ? OR (expires_at = ? AND id < ?)
+    -- NULL times sort last, after every timed row, and are paged by id alone
+    OR (expires_at IS NULL AND (? IS NOT NULL OR id < ?))
+)
+ORDER BY expires_at DESC, id DESC
+LIMIT ?
+`
+
+type GetExpiredMessagesAfterCursorParams struct {
+	CursorExpiresAt sql.NullTime
+	CursorID        int64
+	Limit           int32
+}
+
+type GetExpiredMessagesAfterCursorRow struct {
+	ID             int64
+	RecipientPhone string
+	Content        string
+	Status         MessagesStatus
+	Messageid      sql.NullString
+	SentAt         sql.NullTime
+	Createdon      sql.NullTime
+	RetryCount     int32
+	ExpiresAt      sql.NullTime
+}
+
+func (q *Queries) GetExpiredMessagesAfterCursor(ctx context.Context, arg GetExpiredMessagesAfterCursorParams) ([]GetExpiredMessagesAfterCursorRow, error) {
+	rows, err := q.db.QueryContext(ctx, getExpiredMessagesAfterCursor,
+		arg.CursorExpiresAt,
+		arg.CursorExpiresAt,
+		arg.CursorID,
+		arg.CursorExpiresAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetExpiredMessagesAfterCursorRow
+	for rows.Next() {
+		var i GetExpiredMessagesAfterCursorRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RecipientPhone,
+			&i.Content,
+			&i.Status,
+			&i.Messageid,
+			&i.SentAt,
+			&i.Createdon,
+			&i.RetryCount,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getExpiredMessagesCount = `-- name: GetExpiredMessagesCount :one
+SELECT COUNT(*) as total FROM messages WHERE status = 'expired'
+`
+
+func (q *Queries) GetExpiredMessagesCount(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getExpiredMessagesCount)
+	var total int64
+	err := row.Scan(&total)
+	return total, err
+}
+
+const getMessageAttempts = `-- name: GetMessageAttempts :many
+SELECT id, message_id, status, provider_message_id, error, duration_ms, attempted_at FROM message_attempts
+WHERE message_id = ?
+ORDER BY attempted_at, id
+`
+
+func (q *Queries) GetMessageAttempts(ctx context.Context, messageID int64) ([]MessageAttempt, error) {
+	rows, err := q.db.QueryContext(ctx, getMessageAttempts, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []MessageAttempt
+	for rows.Next() {
+		var i MessageAttempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.MessageID,
+			&i.Status,
+			&i.ProviderMessageID,
+			&i.Error,
+			&i.DurationMs,
+			&i.AttemptedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMessageByClientReference = `-- name: GetMessageByClientReference :one
+SELECT id, recipient_phone, content, status, messageid, sent_at, retry_count, client_reference, scheduled_at, expires_at
+FROM messages
+WHERE client_reference = ?
+`
+
+type GetMessageByClientReferenceRow struct {
+	ID              int64
+	RecipientPhone  string
+	Content         string
+	Status          MessagesStatus
+	Messageid       sql.NullString
+	SentAt          sql.NullTime
+	RetryCount      int32
+	ClientReference sql.NullString
+	ScheduledAt     sql.NullTime
+	ExpiresAt       sql.NullTime
+}
+
+func (q *Queries) GetMessageByClientReference(ctx context.Context, clientReference sql.NullString) (GetMessageByClientReferenceRow, error) {
+	row := q.db.QueryRowContext(ctx, getMessageByClientReference, clientReference)
+	var i GetMessageByClientReferenceRow
+	err := row.Scan(
+		&i.ID,
+		&i.RecipientPhone,
+		&i.Content,
+		&i.Status,
+		&i.Messageid,
+		&i.SentAt,
+		&i.RetryCount,
+		&i.ClientReference,
+		&i.ScheduledAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
+
+const getMessageByID = `-- name: GetMessageByID :one
+SELECT id, recipient_phone, content, status, messageId, sent_at, retry_count, createdOn, updatedOn, client_reference, scheduled_at, expires_at, lease_owner, lease_expires_at, next_attempt_at, last_error, batch_ref FROM messages
+WHERE id = ?
+`
+
+func (q *Queries) GetMessageByID(ctx context.Context, id int64) (Message, error) {
+	row := q.db.QueryRowContext(ctx, getMessageByID, id)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.RecipientPhone,
+		&i.Content,
+		&i.Status,
+		&i.Messageid,
+		&i.SentAt,
+		&i.RetryCount,
+		&i.Createdon,
+		&i.Updatedon,
+		&i.ClientReference,
+		&i.ScheduledAt,
+		&i.ExpiresAt,
+		&i.LeaseOwner,
+		&i.LeaseExpiresAt,
+		&i.NextAttemptAt,
+		&i.LastError,
+		&i.BatchRef,
+	)
+	return i, err
+}
+
+const getMessageIDsByBatchRefs = `-- name: GetMessageIDsByBatchRefs :many
+SELECT id, batch_ref
+FROM messages
+WHERE batch_ref IN (/*SLICE:batch_refs*/?)
+`
+
+type GetMessageIDsByBatchRefsRow struct {
+	ID       int64
+	BatchRef sql.NullString
+}
+
+func (q *Queries) GetMessageIDsByBatchRefs(ctx context.Context, batchRefs []sql.NullString) ([]GetMessageIDsByBatchRefsRow, error) {
+	query := getMessageIDsByBatchRefs
+	var queryParams []interface{}
+	if len(batchRefs) > 0 {
+		for _, v := range batchRefs {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:batch_refs*/?", strings.Repeat(",?", len(batchRefs))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:batch_refs*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMessageIDsByBatchRefsRow
+	for rows.Next() {
+		var i GetMessageIDsByBatchRefsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.BatchRef,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMessagesByClientReferences = `-- name: GetMessagesByClientReferences :many
+SELECT id, recipient_phone, content, status, messageid, sent_at, retry_count, client_reference, scheduled_at, expires_at
+FROM messages
+WHERE client_reference IN (/*SLICE:client_references*/?)
+`
+
+type GetMessagesByClientReferencesRow struct {
+	ID              int64
+	RecipientPhone  string
+	Content         string
+	Status          MessagesStatus
+	Messageid       sql.NullString
+	SentAt          sql.NullTime
+	RetryCount      int32
+	ClientReference sql.NullString
+	ScheduledAt     sql.NullTime
+	ExpiresAt       sql.NullTime
+}
+
+func (q *Queries) GetMessagesByClientReferences(ctx context.Context, clientReferences []sql.NullString) ([]GetMessagesByClientReferencesRow, error) {
+	query := getMessagesByClientReferences
+	var queryParams []interface{}
+	if len(clientReferences) > 0 {
+		for _, v := range clientReferences {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:client_references*/?", strings.Repeat(",?", len(clientReferences))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:client_references*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMessagesByClientReferencesRow
+	for rows.Next() {
+		var i GetMessagesByClientReferencesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RecipientPhone,
+			&i.Content,
+			&i.Status,
+			&i.Messageid,
+			&i.SentAt,
+			&i.RetryCount,
+			&i.ClientReference,
+			&i.ScheduledAt,
+			&i.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPendingMessage = `-- name: GetPendingMessage :many
-SELECT id, recipient_phone, content, status, messageid, sent_at, retry_count
+SELECT id, recipient_phone, content, status, messageid, sent_at, retry_count, scheduled_at, expires_at
 FROM messages
 WHERE status in ('pending','failed')
-AND (retry_count < 3 OR status = 'pending')
-LIMIT 2
+AND (retry_count < ? OR status = 'pending')
+AND (scheduled_at IS NULL OR scheduled_at <= ?)
+AND (next_attempt_at IS NULL OR next_attempt_at <= ?)
+ORDER BY id ASC
+LIMIT ?
+FOR UPDATE SKIP LOCKED
 `
 
+type GetPendingMessageParams struct {
+	MaxAttempts int32
+	Now         sql.NullTime
+	Limit       int32
+}
+
 type GetPendingMessageRow struct {
 	ID             int64
 	RecipientPhone string
@@ -26,10 +553,17 @@ type GetPendingMessageRow struct {
 	Messageid      sql.NullString
 	SentAt         sql.NullTime
 	RetryCount     int32
+	ScheduledAt    sql.NullTime
+	ExpiresAt      sql.NullTime
 }
 
-func (q *Queries) GetPendingMessage(ctx context.Context) ([]GetPendingMessageRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPendingMessage)
+func (q *Queries) GetPendingMessage(ctx context.Context, arg GetPendingMessageParams) ([]GetPendingMessageRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPendingMessage,
+		arg.MaxAttempts,
+		arg.Now,
+		arg.Now,
+		arg.Limit,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -45,6 +579,8 @@ func (q *Queries) GetPendingMessage(ctx context.Context) ([]GetPendingMessageRow
 			&i.Messageid,
 			&i.SentAt,
 			&i.RetryCount,
+			&i.ScheduledAt,
+			&i.ExpiresAt,
 		); err != nil {
 			return nil, err
 		}
@@ -114,6 +650,74 @@ func (q *Queries) GetSentMessages(ctx context.Context, arg GetSentMessagesParams
 	return items, nil
 }
 
+const getSentMessagesAfterCursor = `-- name: GetSentMessagesAfterCursor :many
+SELECT id, recipient_phone, content, status, messageid, sent_at, createdon, retry_count FROM messages
+WHERE status = 'sent'
+AND (
+    sent_at < ? OR (sent_at = ? AND id < ?)
+    -- NULL times sort last, after every timed row, and are paged by id alone
+    OR (sent_at IS NULL AND (? IS NOT NULL OR id < ?))
+)
+ORDER BY sent_at DESC, id DESC
+LIMIT ?
+`
+
+type GetSentMessagesAfterCursorParams struct {
+	CursorSentAt sql.NullTime
+	CursorID     int64
+	Limit        int32
+}
+
+type GetSentMessagesAfterCursorRow struct {
+	ID             int64
+	RecipientPhone string
+	Content        string
+	Status         MessagesStatus
+	Messageid      sql.NullString
+	SentAt         sql.NullTime
+	Createdon      sql.NullTime
+	RetryCount     int32
+}
+
+func (q *Queries) GetSentMessagesAfterCursor(ctx context.Context, arg GetSentMessagesAfterCursorParams) ([]GetSentMessagesAfterCursorRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSentMessagesAfterCursor,
+		arg.CursorSentAt,
+		arg.CursorSentAt,
+		arg.CursorID,
+		arg.CursorSentAt,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetSentMessagesAfterCursorRow
+	for rows.Next() {
+		var i GetSentMessagesAfterCursorRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.RecipientPhone,
+			&i.Content,
+			&i.Status,
+			&i.Messageid,
+			&i.SentAt,
+			&i.Createdon,
+			&i.RetryCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getSentMessagesCount = `-- name: GetSentMessagesCount :one
 SELECT COUNT(*) as total FROM messages WHERE status = 'sent'
 `
@@ -125,7 +729,226 @@ func (q *Queries) GetSentMessagesCount(ctx context.Context) (int64, error) {
 	return total, err
 }
 
-const updateMessage = `-- name: UpdateMessage :exec
+const recoverExpiredLeases = `-- name: RecoverExpiredLeases :execrows
+UPDATE messages
+SET status = CASE WHEN retry_count + 1 >= ? THEN 'dead' ELSE 'failed' END,
+    retry_count = retry_count + 1,
+    last_error = 'dispatch lease expired before the send completed',
+    lease_owner = NULL,
+    lease_expires_at = NULL
+WHERE status = 'processing'
+AND lease_expires_at <= ?
+`
+
+type RecoverExpiredLeasesParams struct {
+	MaxAttempts int32
+	Now         sql.NullTime
+}
+
+func (q *Queries) RecoverExpiredLeases(ctx context.Context, arg RecoverExpiredLeasesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, recoverExpiredLeases, arg.MaxAttempts, arg.Now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const releaseMessages = `-- name: ReleaseMessages :execrows
+UPDATE messages
+SET status = CASE WHEN retry_count > 0 THEN 'failed' ELSE 'pending' END, lease_owner = NULL, lease_expires_at = NULL
+WHERE status = 'processing'
+AND lease_owner = ?
+AND id IN (/*SLICE:ids*/?)
+`
+
+type ReleaseMessagesParams struct {
+	LeaseOwner sql.NullString
+	Ids        []int64
+}
+
+func (q *Queries) ReleaseMessages(ctx context.Context, arg ReleaseMessagesParams) (int64, error) {
+	query := releaseMessages
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.LeaseOwner)
+	if len(arg.Ids) > 0 {
+		for _, v := range arg.Ids {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:ids*/?", strings.Repeat(",?", len(arg.Ids))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:ids*/?", "NULL", 1)
+	}
+	result, err := q.db.ExecContext(ctx, query, queryParams...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const requeueDeadMessages = `-- name: RequeueDeadMessages :execrows
+UPDATE messages
+SET status = 'pending', retry_count = 0, next_attempt_at = NULL, last_error = NULL
+WHERE status = 'dead'
+AND (? IS NULL OR recipient_phone = ?)
+AND (? IS NULL OR createdOn >= ?)
+AND (? IS NULL OR createdOn < ?)
+AND (? IS NULL OR content LIKE ?)
+`
+
+type RequeueDeadMessagesParams struct {
+	RecipientPhone sql.NullString
+	CreatedFrom    sql.NullTime
+	CreatedTo      sql.NullTime
+	ContentPattern sql.NullString
+}
+
+func (q *Queries) RequeueDeadMessages(ctx context.Context, arg RequeueDeadMessagesParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, requeueDeadMessages,
+		arg.RecipientPhone,
+		arg.RecipientPhone,
+		arg.CreatedFrom,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.CreatedTo,
+		arg.ContentPattern,
+		arg.ContentPattern,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const requeueMessage = `-- name: RequeueMessage :execrows
+UPDATE messages
+SET status = 'pending', retry_count = 0, next_attempt_at = NULL, last_error = NULL
+WHERE id = ?
+AND status = 'dead'
+`
+
+func (q *Queries) RequeueMessage(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, requeueMessage, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const searchMessages = `-- name: SearchMessages :many
+SELECT id, recipient_phone, content, status, messageId, sent_at, retry_count, createdOn, updatedOn, client_reference, scheduled_at, expires_at, lease_owner, lease_expires_at, next_attempt_at, last_error, batch_ref FROM messages
+WHERE (? IS NULL OR status = ?)
+AND (? IS NULL OR recipient_phone = ?)
+AND (? IS NULL OR createdOn >= ?)
+AND (? IS NULL OR createdOn < ?)
+AND (? IS NULL OR content LIKE ?)
+AND (? IS NULL OR id < ?)
+ORDER BY id DESC
+LIMIT ? OFFSET ?
+`
+
+type SearchMessagesParams struct {
+	Status         NullMessagesStatus
+	RecipientPhone sql.NullString
+	CreatedFrom    sql.NullTime
+	CreatedTo      sql.NullTime
+	ContentPattern sql.NullString
+	CursorID       sql.NullInt64
+	Limit          int32
+	Offset         int32
+}
+
+func (q *Queries) SearchMessages(ctx context.Context, arg SearchMessagesParams) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, searchMessages,
+		arg.Status,
+		arg.Status,
+		arg.RecipientPhone,
+		arg.RecipientPhone,
+		arg.CreatedFrom,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.CreatedTo,
+		arg.ContentPattern,
+		arg.ContentPattern,
+		arg.CursorID,
+		arg.CursorID,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.RecipientPhone,
+			&i.Content,
+			&i.Status,
+			&i.Messageid,
+			&i.SentAt,
+			&i.RetryCount,
+			&i.Createdon,
+			&i.Updatedon,
+			&i.ClientReference,
+			&i.ScheduledAt,
+			&i.ExpiresAt,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
+			&i.NextAttemptAt,
+			&i.LastError,
+			&i.BatchRef,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchMessagesCount = `-- name: SearchMessagesCount :one
+SELECT COUNT(*) as total FROM messages
+WHERE (? IS NULL OR status = ?)
+AND (? IS NULL OR recipient_phone = ?)
+AND (? IS NULL OR createdOn >= ?)
+AND (? IS NULL OR createdOn < ?)
+AND (? IS NULL OR content LIKE ?)
+`
+
+type SearchMessagesCountParams struct {
+	Status         NullMessagesStatus
+	RecipientPhone sql.NullString
+	CreatedFrom    sql.NullTime
+	CreatedTo      sql.NullTime
+	ContentPattern sql.NullString
+}
+
+func (q *Queries) SearchMessagesCount(ctx context.Context, arg SearchMessagesCountParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, searchMessagesCount,
+		arg.Status,
+		arg.Status,
+		arg.RecipientPhone,
+		arg.RecipientPhone,
+		arg.CreatedFrom,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.CreatedTo,
+		arg.ContentPattern,
+		arg.ContentPattern,
+	)
+	var total int64
+	err := row.Scan(&total)
+	return total, err
+}
+
+const updateMessage = `-- name: UpdateMessage :execrows
 UPDATE messages
 SET
     content = COALESCE(?, content),
@@ -133,28 +956,44 @@ SET
     messageId = COALESCE(?, messageId),
     sent_at = COALESCE(?, sent_at),
     retry_count = CASE
-        WHEN ? = 'failed' THEN retry_count + 1
+        WHEN ? = 'failed' OR ? = 'dead' THEN retry_count + 1
         ELSE retry_count
-    END
+    END,
+    next_attempt_at = ?,
+    last_error = COALESCE(?, last_error),
+    lease_owner = NULL,
+    lease_expires_at = NULL
 WHERE id = ?
+AND status = 'processing'
+AND lease_owner = ?
 `
 
 type UpdateMessageParams struct {
-	Content   sql.NullString
-	Status    NullMessagesStatus
-	MessageId sql.NullString
-	SentAt    sql.NullTime
-	ID        int64
+	Content       sql.NullString
+	Status        NullMessagesStatus
+	MessageId     sql.NullString
+	SentAt        sql.NullTime
+	NextAttemptAt sql.NullTime
+	LastError     sql.NullString
+	ID            int64
+	LeaseOwner    sql.NullString
 }
 
-func (q *Queries) UpdateMessage(ctx context.Context, arg UpdateMessageParams) error {
-	_, err := q.db.ExecContext(ctx, updateMessage,
+func (q *Queries) UpdateMessage(ctx context.Context, arg UpdateMessageParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateMessage,
 		arg.Content,
 		arg.Status,
 		arg.MessageId,
 		arg.SentAt,
 		arg.Status,
+		arg.Status,
+		arg.NextAttemptAt,
+		arg.LastError,
 		arg.ID,
+		arg.LeaseOwner,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
@@ -8,14 +8,62 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"time"
 )
 
+type MessageAttemptsStatus string
+
+const (
+	MessageAttemptsStatusSent   MessageAttemptsStatus = "sent"
+	MessageAttemptsStatusFailed MessageAttemptsStatus = "failed"
+)
+
+func (e *MessageAttemptsStatus) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = MessageAttemptsStatus(s)
+	case string:
+		*e = MessageAttemptsStatus(s)
+	default:
+		return fmt.Errorf("unsupported scan type for MessageAttemptsStatus: %T", src)
+	}
+	return nil
+}
+
+type NullMessageAttemptsStatus struct {
+	MessageAttemptsStatus MessageAttemptsStatus
+	Valid                 bool // Valid is true if MessageAttemptsStatus is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullMessageAttemptsStatus) Scan(value interface{}) error {
+	if value == nil {
+		ns.MessageAttemptsStatus, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.MessageAttemptsStatus.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullMessageAttemptsStatus) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.MessageAttemptsStatus), nil
+}
+
 type MessagesStatus string
 
 const (
-	MessagesStatusPending MessagesStatus = "pending"
-	MessagesStatusFailed  MessagesStatus = "failed"
-	MessagesStatusSent    MessagesStatus = "sent"
+	MessagesStatusPending    MessagesStatus = "pending"
+	MessagesStatusFailed     MessagesStatus = "failed"
+	MessagesStatusSent       MessagesStatus = "sent"
+	MessagesStatusExpired    MessagesStatus = "expired"
+	MessagesStatusCancelled  MessagesStatus = "cancelled"
+	MessagesStatusProcessing MessagesStatus = "processing"
+	MessagesStatusDead       MessagesStatus = "dead"
+	MessagesStatusCapped     MessagesStatus = "capped"
 )
 
 func (e *MessagesStatus) Scan(src interface{}) error {
@@ -53,6 +101,22 @@ func (ns NullMessagesStatus) Value() (driver.Value, error) {
 	return string(ns.MessagesStatus), nil
 }
 
+type MessageAttempt struct {
+	ID int64
+	// message this send attempt belongs to
+	MessageID int64
+	// outcome of the send attempt
+	Status MessageAttemptsStatus
+	// message ID returned by the messaging service
+	ProviderMessageID sql.NullString
+	// reason the send attempt failed
+	Error sql.NullString
+	// duration of the messaging service call in milliseconds
+	DurationMs int32
+	// when the send attempt was made
+	AttemptedAt time.Time
+}
+
 type Message struct {
 	ID int64
 	// receipent phone number
@@ -70,4 +134,20 @@ type Message struct {
 	Createdon sql.NullTime
 	// Record last updated timestamp
 	Updatedon sql.NullTime
+	// client supplied idempotency key
+	ClientReference sql.NullString
+	// earliest time the message may be sent, NULL sends on the next poll
+	ScheduledAt sql.NullTime
+	// time after which the message must not be sent, NULL never expires
+	ExpiresAt sql.NullTime
+	// dispatcher instance currently sending the message
+	LeaseOwner sql.NullString
+	// time after which an unfinished claim is recovered
+	LeaseExpiresAt sql.NullTime
+	// earliest time a failed message is retried
+	NextAttemptAt sql.NullTime
+	// reason the most recent send attempt failed
+	LastError sql.NullString
+	// bulk insert that created the message and its position in it
+	BatchRef sql.NullString
 }
@@ -1,11 +1,48 @@
 -- name: GetPendingMessage :many
-SELECT id, recipient_phone, content, status, messageid, sent_at, retry_count
+SELECT id, recipient_phone, content, status, messageid, sent_at, retry_count, scheduled_at, expires_at
 FROM messages
 WHERE status in ('pending','failed')
-AND (retry_count < 3 OR status = 'pending')
-LIMIT 2;
+AND (retry_count < sqlc.arg('max_attempts') OR status = 'pending')
+AND (scheduled_at IS NULL OR scheduled_at <= sqlc.arg('now'))
+AND (next_attempt_at IS NULL OR next_attempt_at <= sqlc.arg('now'))
+ORDER BY id ASC
+LIMIT sqlc.arg('limit')
+FOR UPDATE SKIP LOCKED;
 
--- name: UpdateMessage :exec
+-- name: ClaimMessages :execrows
+UPDATE messages
+SET status = 'processing', lease_owner = sqlc.arg('lease_owner'), lease_expires_at = sqlc.arg('lease_expires_at')
+WHERE id IN (sqlc.slice('ids'));
+
+-- name: RecoverExpiredLeases :execrows
+UPDATE messages
+SET status = CASE WHEN retry_count + 1 >= sqlc.arg('max_attempts') THEN 'dead' ELSE 'failed' END,
+    retry_count = retry_count + 1,
+    last_error = 'dispatch lease expired before the send completed',
+    lease_owner = NULL,
+    lease_expires_at = NULL
+WHERE status = 'processing'
+AND lease_expires_at <= sqlc.arg('now');
+
+-- name: ReleaseMessages :execrows
+UPDATE messages
+SET status = CASE WHEN retry_count > 0 THEN 'failed' ELSE 'pending' END, lease_owner = NULL, lease_expires_at = NULL
+WHERE status = 'processing'
+AND lease_owner = sqlc.arg('lease_owner')
+AND id IN (sqlc.slice('ids'));
+
+-- name: DeferMessage :execrows
+UPDATE messages
+SET status = CASE WHEN retry_count > 0 THEN 'failed' ELSE 'pending' END,
+    next_attempt_at = sqlc.arg('next_attempt_at'),
+    last_error = sqlc.arg('last_error'),
+    lease_owner = NULL,
+    lease_expires_at = NULL
+WHERE id = sqlc.arg('id')
+AND status = 'processing'
+AND lease_owner = sqlc.arg('lease_owner');
+
+-- name: UpdateMessage :execrows
 UPDATE messages
 SET
     content = COALESCE(sqlc.narg('content'), content),
@@ -13,10 +50,16 @@ SET
     messageId = COALESCE(sqlc.narg('messageId'), messageId),
     sent_at = COALESCE(sqlc.narg('sent_at'), sent_at),
     retry_count = CASE
-        WHEN sqlc.narg('status') = 'failed' THEN retry_count + 1
+        WHEN sqlc.narg('status') = 'failed' OR sqlc.narg('status') = 'dead' THEN retry_count + 1
         ELSE retry_count
-    END
-WHERE id = sqlc.arg('id');
+    END,
+    next_attempt_at = sqlc.narg('next_attempt_at'),
+    last_error = COALESCE(sqlc.narg('last_error'), last_error),
+    lease_owner = NULL,
+    lease_expires_at = NULL
+WHERE id = sqlc.arg('id')
+AND status = 'processing'
+AND lease_owner = sqlc.arg('lease_owner');
 
 -- name: GetSentMessages :many
 SELECT id, recipient_phone, content, status, messageid, sent_at, createdon, retry_count FROM messages
@@ -24,5 +67,121 @@ WHERE status = 'sent'
 ORDER BY sent_at DESC, id DESC
 LIMIT ? OFFSET ?;
 
+-- name: GetSentMessagesAfterCursor :many
+SELECT id, recipient_phone, content, status, messageid, sent_at, createdon, retry_count FROM messages
+WHERE status = 'sent'
+AND (
+    sent_at < sqlc.narg('cursor_sent_at') OR (sent_at = sqlc.narg('cursor_sent_at') AND id < sqlc.arg('cursor_id'))
+    -- NULL times sort last, after every timed row, and are paged by id alone
+    OR (sent_at IS NULL AND (sqlc.narg('cursor_sent_at') IS NOT NULL OR id < sqlc.arg('cursor_id')))
+)
+ORDER BY sent_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
 -- name: GetSentMessagesCount :one
 SELECT COUNT(*) as total FROM messages WHERE status = 'sent';
+
+-- name: CreateMessage :execlastid
+INSERT INTO messages (recipient_phone, content, status, client_reference, scheduled_at, expires_at)
+VALUES (?, ?, 'pending', ?, ?, ?);
+
+-- name: GetMessageByClientReference :one
+SELECT id, recipient_phone, content, status, messageid, sent_at, retry_count, client_reference, scheduled_at, expires_at
+FROM messages
+WHERE client_reference = ?;
+
+-- name: GetMessagesByClientReferences :many
+SELECT id, recipient_phone, content, status, messageid, sent_at, retry_count, client_reference, scheduled_at, expires_at
+FROM messages
+WHERE client_reference IN (sqlc.slice('client_references'));
+
+-- name: GetMessageIDsByBatchRefs :many
+SELECT id, batch_ref
+FROM messages
+WHERE batch_ref IN (sqlc.slice('batch_refs'));
+
+-- name: ExpireMessages :execrows
+UPDATE messages
+SET status = 'expired'
+WHERE status in ('pending','failed')
+AND expires_at IS NOT NULL
+AND expires_at <= sqlc.arg('now');
+
+-- name: GetExpiredMessages :many
+SELECT id, recipient_phone, content, status, messageid, sent_at, createdon, retry_count, expires_at FROM messages
+WHERE status = 'expired'
+ORDER BY expires_at DESC, id DESC
+LIMIT ? OFFSET ?;
+
+-- name: GetExpiredMessagesAfterCursor :many
+SELECT id, recipient_phone, content, status, messageid, sent_at, createdon, retry_count, expires_at FROM messages
+WHERE status = 'expired'
+AND (
+    expires_at < sqlc.narg('cursor_expires_at') OR (expires_at = sqlc.narg('cursor_expires_at') AND id < sqlc.arg('cursor_id'))
+    -- NULL times sort last, after every timed row, and are paged by id alone
+    OR (expires_at IS NULL AND (sqlc.narg('cursor_expires_at') IS NOT NULL OR id < sqlc.arg('cursor_id')))
+)
+ORDER BY expires_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetExpiredMessagesCount :one
+SELECT COUNT(*) as total FROM messages WHERE status = 'expired';
+
+-- name: GetMessageByID :one
+SELECT * FROM messages
+WHERE id = ?;
+
+-- name: CancelMessage :execrows
+UPDATE messages
+SET status = 'cancelled'
+WHERE id = ?
+AND status in ('pending','failed');
+
+-- name: CreateMessageAttempt :exec
+INSERT INTO message_attempts (message_id, status, provider_message_id, error, duration_ms, attempted_at)
+VALUES (?, ?, ?, ?, ?, ?);
+
+-- name: GetMessageAttempts :many
+SELECT * FROM message_attempts
+WHERE message_id = ?
+ORDER BY attempted_at, id;
+
+-- name: SearchMessages :many
+SELECT * FROM messages
+WHERE (sqlc.narg('status') IS NULL OR status = sqlc.narg('status'))
+AND (sqlc.narg('recipient_phone') IS NULL OR recipient_phone = sqlc.narg('recipient_phone'))
+AND (sqlc.narg('created_from') IS NULL OR createdOn >= sqlc.narg('created_from'))
+AND (sqlc.narg('created_to') IS NULL OR createdOn < sqlc.narg('created_to'))
+AND (sqlc.narg('content_pattern') IS NULL OR content LIKE sqlc.narg('content_pattern'))
+AND (sqlc.narg('cursor_id') IS NULL OR id < sqlc.narg('cursor_id'))
+ORDER BY id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: SearchMessagesCount :one
+SELECT COUNT(*) as total FROM messages
+WHERE (sqlc.narg('status') IS NULL OR status = sqlc.narg('status'))
+AND (sqlc.narg('recipient_phone') IS NULL OR recipient_phone = sqlc.narg('recipient_phone'))
+AND (sqlc.narg('created_from') IS NULL OR createdOn >= sqlc.narg('created_from'))
+AND (sqlc.narg('created_to') IS NULL OR createdOn < sqlc.narg('created_to'))
+AND (sqlc.narg('content_pattern') IS NULL OR content LIKE sqlc.narg('content_pattern'));
+
+-- name: DeadLetterExhaustedMessages :execrows
+UPDATE messages
+SET status = 'dead', next_attempt_at = NULL
+WHERE status = 'failed'
+AND retry_count >= sqlc.arg('max_attempts');
+
+-- name: RequeueMessage :execrows
+UPDATE messages
+SET status = 'pending', retry_count = 0, next_attempt_at = NULL, last_error = NULL
+WHERE id = ?
+AND status = 'dead';
+
+-- name: RequeueDeadMessages :execrows
+UPDATE messages
+SET status = 'pending', retry_count = 0, next_attempt_at = NULL, last_error = NULL
+WHERE status = 'dead'
+AND (sqlc.narg('recipient_phone') IS NULL OR recipient_phone = sqlc.narg('recipient_phone'))
+AND (sqlc.narg('created_from') IS NULL OR createdOn >= sqlc.narg('created_from'))
+AND (sqlc.narg('created_to') IS NULL OR createdOn < sqlc.narg('created_to'))
+AND (sqlc.narg('content_pattern') IS NULL OR content LIKE sqlc.narg('content_pattern'));
@@ -2,13 +2,40 @@ CREATE TABLE `messages` (
   `id` bigint NOT NULL AUTO_INCREMENT,
   `recipient_phone` VARCHAR(20) NOT NULL COMMENT 'receipent phone number',
   `content` varchar(200) NOT NULL COMMENT 'message content',
-  `status` ENUM('pending', 'failed', 'sent') NOT NULL DEFAULT 'pending',
+  `status` ENUM('pending', 'failed', 'sent', 'expired', 'cancelled', 'processing', 'dead', 'capped') NOT NULL DEFAULT 'pending',
   `messageId` VARCHAR(100) DEFAULT NULL COMMENT 'message ID from the messaging service',
   `sent_at` datetime DEFAULT NULL COMMENT 'when the message was sent',
   `retry_count` int NOT NULL DEFAULT 0 COMMENT 'number of retry attempts',
   `createdOn` datetime DEFAULT CURRENT_TIMESTAMP COMMENT 'Record creation timestamp',
   `updatedOn` datetime DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Record last updated timestamp',
+  `client_reference` VARCHAR(100) DEFAULT NULL COMMENT 'client supplied idempotency key',
+  `scheduled_at` datetime DEFAULT NULL COMMENT 'earliest time the message may be sent, NULL sends on the next poll',
+  `expires_at` datetime DEFAULT NULL COMMENT 'time after which the message must not be sent, NULL never expires',
+  `lease_owner` VARCHAR(64) DEFAULT NULL COMMENT 'dispatcher instance currently sending the message',
+  `lease_expires_at` datetime DEFAULT NULL COMMENT 'time after which an unfinished claim is recovered',
+  `next_attempt_at` datetime DEFAULT NULL COMMENT 'earliest time a failed message is retried',
+  `last_error` VARCHAR(500) DEFAULT NULL COMMENT 'reason the most recent send attempt failed',
+  `batch_ref` VARCHAR(64) DEFAULT NULL COMMENT 'bulk insert that created the message and its position in it',
   PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_messages_client_reference` (`client_reference`),
+  KEY `idx_messages_status_scheduled_at` (`status`, `scheduled_at`),
+  KEY `idx_messages_status_lease_expires_at` (`status`, `lease_expires_at`),
+  KEY `idx_messages_status_expires_at` (`status`, `expires_at`, `id`),
+  KEY `idx_messages_status_sent_at` (`status`, `sent_at`, `id`),
   KEY `idx_messages_status` (`status`),
-  KEY `idx_messages_recipient_phone` (`recipient_phone`)
+  KEY `idx_messages_recipient_phone` (`recipient_phone`),
+  KEY `idx_messages_created_on` (`createdOn`),
+  KEY `idx_messages_batch_ref` (`batch_ref`)
+) ;
+
+CREATE TABLE `message_attempts` (
+  `id` bigint NOT NULL AUTO_INCREMENT,
+  `message_id` bigint NOT NULL COMMENT 'message this send attempt belongs to',
+  `status` ENUM('sent', 'failed') NOT NULL COMMENT 'outcome of the send attempt',
+  `provider_message_id` VARCHAR(100) DEFAULT NULL COMMENT 'message ID returned by the messaging service',
+  `error` VARCHAR(500) DEFAULT NULL COMMENT 'reason the send attempt failed',
+  `duration_ms` int NOT NULL DEFAULT 0 COMMENT 'duration of the messaging service call in milliseconds',
+  `attempted_at` datetime NOT NULL COMMENT 'when the send attempt was made',
+  PRIMARY KEY (`id`),
+  KEY `idx_message_attempts_message_id` (`message_id`)
 ) ;
@@ -2,19 +2,158 @@ package dto
 
 import (
 	"database/sql"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/smitendu1997/auto-message-dispatcher/domain"
 	"github.com/smitendu1997/auto-message-dispatcher/models/db"
 )
 
-func ConvertUpdateMessageParamsToDomain(r *domain.MessageDomain) db.UpdateMessageParams {
-	return db.UpdateMessageParams{
-		ID:        r.ID,
-		Content:   sql.NullString{String: r.Content, Valid: r.Content != ""},
-		Status:    db.NullMessagesStatus{MessagesStatus: db.MessagesStatus(string(r.Status)), Valid: r.Status.IsValid()},
-		MessageId: sql.NullString{String: *r.MessageID, Valid: r.MessageID != nil},
-		SentAt:    sql.NullTime{Time: *r.SentAt, Valid: r.SentAt != nil},
+func ConvertUpdateMessageParamsToDomain(r *domain.MessageDomain, owner string) db.UpdateMessageParams {
+	params := db.UpdateMessageParams{
+		ID:         r.ID,
+		LeaseOwner: sql.NullString{String: owner, Valid: true},
+		Content:    sql.NullString{String: r.Content, Valid: r.Content != ""},
+		Status:     db.NullMessagesStatus{MessagesStatus: db.MessagesStatus(string(r.Status)), Valid: r.Status.IsValid()},
 	}
+	if r.MessageID != nil {
+		params.MessageId = sql.NullString{String: *r.MessageID, Valid: true}
+	}
+	if r.SentAt != nil {
+		params.SentAt = sql.NullTime{Time: *r.SentAt, Valid: true}
+	}
+	if r.NextAttemptAt != nil {
+		params.NextAttemptAt = sql.NullTime{Time: *r.NextAttemptAt, Valid: true}
+	}
+	if r.LastError != nil {
+		params.LastError = sql.NullString{String: truncateError(*r.LastError), Valid: true}
+	}
+	return params
+}
+
+func ConvertDeferMessageToParams(id int64, nextAttemptAt time.Time, reason, owner string) db.DeferMessageParams {
+	return db.DeferMessageParams{
+		ID:            id,
+		LeaseOwner:    sql.NullString{String: owner, Valid: true},
+		NextAttemptAt: sql.NullTime{Time: nextAttemptAt, Valid: true},
+		LastError:     sql.NullString{String: truncateError(reason), Valid: true},
+	}
+}
+
+func ConvertMessageDomainToCreateMessageParams(r *domain.MessageDomain) db.CreateMessageParams {
+	params := db.CreateMessageParams{
+		RecipientPhone: r.RecipientPhone,
+		Content:        r.Content,
+	}
+	if r.ClientReference != nil {
+		params.ClientReference = sql.NullString{String: *r.ClientReference, Valid: true}
+	}
+	if r.ScheduledAt != nil {
+		params.ScheduledAt = sql.NullTime{Time: *r.ScheduledAt, Valid: true}
+	}
+	if r.ExpiresAt != nil {
+		params.ExpiresAt = sql.NullTime{Time: *r.ExpiresAt, Valid: true}
+	}
+	return params
+}
+
+// maxErrorLength mirrors the VARCHAR(500) limit of message_attempts.error and messages.last_error
+const maxErrorLength = 500
+
+func truncateError(errMsg string) string {
+	if utf8.RuneCountInString(errMsg) > maxErrorLength {
+		return string([]rune(errMsg)[:maxErrorLength])
+	}
+	return errMsg
+}
+
+func ConvertMessageAttemptDomainToCreateParams(r *domain.MessageAttempt) db.CreateMessageAttemptParams {
+	params := db.CreateMessageAttemptParams{
+		MessageID:   r.MessageID,
+		Status:      db.MessageAttemptsStatus(r.Status),
+		DurationMs:  int32(r.Duration.Milliseconds()),
+		AttemptedAt: r.AttemptedAt,
+	}
+	if r.ProviderMessageID != nil {
+		params.ProviderMessageID = sql.NullString{String: *r.ProviderMessageID, Valid: true}
+	}
+	if r.Error != nil {
+		params.Error = sql.NullString{String: truncateError(*r.Error), Valid: true}
+	}
+	return params
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func ConvertMessageFilterToSearchMessagesCountParams(f *domain.MessageFilter) db.SearchMessagesCountParams {
+	var params db.SearchMessagesCountParams
+	if f.Status != nil {
+		params.Status = db.NullMessagesStatus{MessagesStatus: db.MessagesStatus(*f.Status), Valid: true}
+	}
+	if f.RecipientPhone != nil {
+		params.RecipientPhone = sql.NullString{String: *f.RecipientPhone, Valid: true}
+	}
+	if f.CreatedFrom != nil {
+		params.CreatedFrom = sql.NullTime{Time: *f.CreatedFrom, Valid: true}
+	}
+	if f.CreatedTo != nil {
+		params.CreatedTo = sql.NullTime{Time: *f.CreatedTo, Valid: true}
+	}
+	if f.Query != nil {
+		params.ContentPattern = sql.NullString{String: "%" + likeEscaper.Replace(*f.Query) + "%", Valid: true}
+	}
+	return params
+}
+
+func ConvertMessageFilterToSearchMessagesParams(f *domain.MessageFilter, limit, offset int32, cursor *domain.MessageCursor) db.SearchMessagesParams {
+	countParams := ConvertMessageFilterToSearchMessagesCountParams(f)
+	params := db.SearchMessagesParams{
+		Status:         countParams.Status,
+		RecipientPhone: countParams.RecipientPhone,
+		CreatedFrom:    countParams.CreatedFrom,
+		CreatedTo:      countParams.CreatedTo,
+		ContentPattern: countParams.ContentPattern,
+		Limit:          limit,
+		Offset:         offset,
+	}
+	if cursor != nil {
+		params.CursorID = sql.NullInt64{Int64: cursor.ID, Valid: true}
+		params.Offset = 0
+	}
+	return params
+}
+
+func ConvertMessageFilterToRequeueDeadMessagesParams(f *domain.MessageFilter) db.RequeueDeadMessagesParams {
+	countParams := ConvertMessageFilterToSearchMessagesCountParams(f)
+	return db.RequeueDeadMessagesParams{
+		RecipientPhone: countParams.RecipientPhone,
+		CreatedFrom:    countParams.CreatedFrom,
+		CreatedTo:      countParams.CreatedTo,
+		ContentPattern: countParams.ContentPattern,
+	}
+}
+
+func ConvertCursorToGetSentMessagesAfterCursorParams(cursor *domain.MessageCursor, limit int32) db.GetSentMessagesAfterCursorParams {
+	params := db.GetSentMessagesAfterCursorParams{
+		CursorID: cursor.ID,
+		Limit:    limit,
+	}
+	if cursor.SortTime != nil {
+		params.CursorSentAt = sql.NullTime{Time: *cursor.SortTime, Valid: true}
+	}
+	return params
+}
+
+func ConvertCursorToGetExpiredMessagesAfterCursorParams(cursor *domain.MessageCursor, limit int32) db.GetExpiredMessagesAfterCursorParams {
+	params := db.GetExpiredMessagesAfterCursorParams{
+		CursorID: cursor.ID,
+		Limit:    limit,
+	}
+	if cursor.SortTime != nil {
+		params.CursorExpiresAt = sql.NullTime{Time: *cursor.SortTime, Valid: true}
+	}
+	return params
 }
 
 // PaginationRequest represents pagination parameters for listing messages
@@ -1,6 +1,8 @@
 package dto
 
 import (
+	"time"
+
 	"github.com/smitendu1997/auto-message-dispatcher/domain"
 	"github.com/smitendu1997/auto-message-dispatcher/models/db"
 )
@@ -11,6 +13,7 @@ func ConvertGetPendingMessageRowToMessageDomain(row *db.GetPendingMessageRow) *d
 		RecipientPhone: row.RecipientPhone,
 		Content:        row.Content,
 		Status:         domain.MessageStatus(string(row.Status)),
+		RetryCount:     int(row.RetryCount),
 	}
 	if row.Messageid.Valid {
 		result.MessageID = &row.Messageid.String
@@ -18,6 +21,12 @@ func ConvertGetPendingMessageRowToMessageDomain(row *db.GetPendingMessageRow) *d
 	if row.SentAt.Valid {
 		result.SentAt = &row.SentAt.Time
 	}
+	if row.ScheduledAt.Valid {
+		result.ScheduledAt = &row.ScheduledAt.Time
+	}
+	if row.ExpiresAt.Valid {
+		result.ExpiresAt = &row.ExpiresAt.Time
+	}
 	return result
 }
 
@@ -48,5 +57,181 @@ func ConvertGetSentMessageRowToMessageDomain(row *db.GetSentMessagesRow) *domain
 	if row.SentAt.Valid {
 		result.SentAt = &row.SentAt.Time
 	}
+	if row.Createdon.Valid {
+		result.CreatedOn = &row.Createdon.Time
+	}
+	return result
+}
+
+func ConvertGetMessageByClientReferenceRowToMessageDomain(row *db.GetMessageByClientReferenceRow) *domain.MessageDomain {
+	result := &domain.MessageDomain{
+		ID:             row.ID,
+		RecipientPhone: row.RecipientPhone,
+		Content:        row.Content,
+		Status:         domain.MessageStatus(row.Status),
+		RetryCount:     int(row.RetryCount),
+	}
+	if row.Messageid.Valid {
+		result.MessageID = &row.Messageid.String
+	}
+	if row.SentAt.Valid {
+		result.SentAt = &row.SentAt.Time
+	}
+	if row.ClientReference.Valid {
+		result.ClientReference = &row.ClientReference.String
+	}
+	if row.ScheduledAt.Valid {
+		result.ScheduledAt = &row.ScheduledAt.Time
+	}
+	if row.ExpiresAt.Valid {
+		result.ExpiresAt = &row.ExpiresAt.Time
+	}
+	return result
+}
+
+func ConvertGetMessagesByClientReferencesRowToMessageDomain(row *db.GetMessagesByClientReferencesRow) *domain.MessageDomain {
+	result := &domain.MessageDomain{
+		ID:             row.ID,
+		RecipientPhone: row.RecipientPhone,
+		Content:        row.Content,
+		Status:         domain.MessageStatus(row.Status),
+		RetryCount:     int(row.RetryCount),
+	}
+	if row.Messageid.Valid {
+		result.MessageID = &row.Messageid.String
+	}
+	if row.SentAt.Valid {
+		result.SentAt = &row.SentAt.Time
+	}
+	if row.ClientReference.Valid {
+		result.ClientReference = &row.ClientReference.String
+	}
+	if row.ScheduledAt.Valid {
+		result.ScheduledAt = &row.ScheduledAt.Time
+	}
+	if row.ExpiresAt.Valid {
+		result.ExpiresAt = &row.ExpiresAt.Time
+	}
+	return result
+}
+
+func ConvertGetExpiredMessageRowToMessageDomain(row *db.GetExpiredMessagesRow) *domain.MessageDomain {
+	result := &domain.MessageDomain{
+		ID:             row.ID,
+		RecipientPhone: row.RecipientPhone,
+		Content:        row.Content,
+		Status:         domain.MessageStatus(row.Status),
+		RetryCount:     int(row.RetryCount),
+	}
+	if row.Messageid.Valid {
+		result.MessageID = &row.Messageid.String
+	}
+	if row.SentAt.Valid {
+		result.SentAt = &row.SentAt.Time
+	}
+	if row.ExpiresAt.Valid {
+		result.ExpiresAt = &row.ExpiresAt.Time
+	}
+	if row.Createdon.Valid {
+		result.CreatedOn = &row.Createdon.Time
+	}
+	return result
+}
+
+func ConvertMessageToMessageDomain(row *db.Message) *domain.MessageDomain {
+	result := &domain.MessageDomain{
+		ID:             row.ID,
+		RecipientPhone: row.RecipientPhone,
+		Content:        row.Content,
+		Status:         domain.MessageStatus(row.Status),
+		RetryCount:     int(row.RetryCount),
+	}
+	if row.Messageid.Valid {
+		result.MessageID = &row.Messageid.String
+	}
+	if row.SentAt.Valid {
+		result.SentAt = &row.SentAt.Time
+	}
+	if row.ClientReference.Valid {
+		result.ClientReference = &row.ClientReference.String
+	}
+	if row.ScheduledAt.Valid {
+		result.ScheduledAt = &row.ScheduledAt.Time
+	}
+	if row.ExpiresAt.Valid {
+		result.ExpiresAt = &row.ExpiresAt.Time
+	}
+	if row.Createdon.Valid {
+		result.CreatedOn = &row.Createdon.Time
+	}
+	if row.Updatedon.Valid {
+		result.UpdatedOn = &row.Updatedon.Time
+	}
+	if row.NextAttemptAt.Valid {
+		result.NextAttemptAt = &row.NextAttemptAt.Time
+	}
+	if row.LastError.Valid {
+		result.LastError = &row.LastError.String
+	}
+	return result
+}
+
+func ConvertMessageAttemptToDomain(row *db.MessageAttempt) *domain.MessageAttempt {
+	result := &domain.MessageAttempt{
+		ID:          row.ID,
+		MessageID:   row.MessageID,
+		Status:      domain.MessageStatus(row.Status),
+		Duration:    time.Duration(row.DurationMs) * time.Millisecond,
+		AttemptedAt: row.AttemptedAt,
+	}
+	if row.ProviderMessageID.Valid {
+		result.ProviderMessageID = &row.ProviderMessageID.String
+	}
+	if row.Error.Valid {
+		result.Error = &row.Error.String
+	}
+	return result
+}
+
+func ConvertGetSentMessagesAfterCursorRowToMessageDomain(row *db.GetSentMessagesAfterCursorRow) *domain.MessageDomain {
+	result := &domain.MessageDomain{
+		ID:             row.ID,
+		RecipientPhone: row.RecipientPhone,
+		Content:        row.Content,
+		Status:         domain.MessageStatus(row.Status),
+		RetryCount:     int(row.RetryCount),
+	}
+	if row.Messageid.Valid {
+		result.MessageID = &row.Messageid.String
+	}
+	if row.SentAt.Valid {
+		result.SentAt = &row.SentAt.Time
+	}
+	if row.Createdon.Valid {
+		result.CreatedOn = &row.Createdon.Time
+	}
+	return result
+}
+
+func ConvertGetExpiredMessagesAfterCursorRowToMessageDomain(row *db.GetExpiredMessagesAfterCursorRow) *domain.MessageDomain {
+	result := &domain.MessageDomain{
+		ID:             row.ID,
+		RecipientPhone: row.RecipientPhone,
+		Content:        row.Content,
+		Status:         domain.MessageStatus(row.Status),
+		RetryCount:     int(row.RetryCount),
+	}
+	if row.Messageid.Valid {
+		result.MessageID = &row.Messageid.String
+	}
+	if row.SentAt.Valid {
+		result.SentAt = &row.SentAt.Time
+	}
+	if row.ExpiresAt.Valid {
+		result.ExpiresAt = &row.ExpiresAt.Time
+	}
+	if row.Createdon.Valid {
+		result.CreatedOn = &row.Createdon.Time
+	}
 	return result
 }
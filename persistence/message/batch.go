@@ -0,0 +1,48 @@
+package message
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/smitendu1997/auto-message-dispatcher/models/db"
+)
+
+// sqlc cannot generate an INSERT with a variable number of rows, insertMessages
+// reuses the columns of the generated CreateMessage query to insert a whole
+// chunk in a single statement.
+
+const insertMessagesPrefix = `INSERT INTO messages (recipient_phone, content, status, client_reference, scheduled_at, expires_at, batch_ref)
+VALUES `
+
+const insertMessagesRow = `(?, ?, 'pending', ?, ?, ?, ?)`
+
+// batchRef names a row of a bulk insert so its id can be read back. Auto-increment values of a
+// multi-row INSERT are not guaranteed to be consecutive, so the ids are never derived from the first one.
+func batchRef(token string, position int) sql.NullString {
+	return sql.NullString{String: fmt.Sprintf("%s:%d", token, position), Valid: true}
+}
+
+// insertMessages inserts every row with one multi-row INSERT, tagging each with its batch ref
+func insertMessages(ctx context.Context, tx *sql.Tx, args []db.CreateMessageParams, refs []sql.NullString) error {
+	var query strings.Builder
+	query.WriteString(insertMessagesPrefix)
+	params := make([]interface{}, 0, 6*len(args))
+	for i, arg := range args {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString(insertMessagesRow)
+		params = append(params,
+			arg.RecipientPhone,
+			arg.Content,
+			arg.ClientReference,
+			arg.ScheduledAt,
+			arg.ExpiresAt,
+			refs[i],
+		)
+	}
+	_, err := tx.ExecContext(ctx, query.String(), params...)
+	return err
+}
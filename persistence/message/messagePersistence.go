@@ -3,48 +3,163 @@ package message
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
 
 	"github.com/smitendu1997/auto-message-dispatcher/dbdebug"
 	"github.com/smitendu1997/auto-message-dispatcher/domain"
 	"github.com/smitendu1997/auto-message-dispatcher/models/db"
 	"github.com/smitendu1997/auto-message-dispatcher/persistence/dto"
+	"github.com/smitendu1997/auto-message-dispatcher/utils"
 )
 
 func NewMessagePersistence(DB *sql.DB) MessagePersistence {
 	querier := db.New(dbdebug.Wrap(DB))
-	return &Message{Querier: querier}
+	return &Message{Querier: querier, DB: DB}
 }
 
 type MessagePersistence interface {
-	GetPendingMessages(ctx context.Context) ([]*domain.MessageDomain, error)
-	UpdateMessage(ctx context.Context, msg *domain.MessageDomain) error
+	ClaimPendingMessages(ctx context.Context, now time.Time, limit int32, maxAttempts int, owner string, leaseExpiresAt time.Time) ([]*domain.MessageDomain, error)
+	RecoverExpiredLeases(ctx context.Context, now time.Time, maxAttempts int) (int64, error)
+	DeadLetterExhaustedMessages(ctx context.Context, maxAttempts int) (int64, error)
+	ReleaseMessages(ctx context.Context, ids []int64, owner string) (int64, error)
+	DeferMessage(ctx context.Context, id int64, nextAttemptAt time.Time, reason, owner string) (bool, error)
+	UpdateMessage(ctx context.Context, msg *domain.MessageDomain, owner string) (bool, error)
 	GetSentMessages(ctx context.Context, limit, offset int32) ([]*domain.MessageDomain, error)
+	GetSentMessagesAfterCursor(ctx context.Context, cursor *domain.MessageCursor, limit int32) ([]*domain.MessageDomain, error)
 	GetSentMessagesCount(ctx context.Context) (int64, error)
+	CreateMessage(ctx context.Context, msg *domain.MessageDomain) (int64, error)
+	CreateMessages(ctx context.Context, msgs []*domain.MessageDomain) ([]int64, error)
+	GetMessageByClientReference(ctx context.Context, clientReference string) (*domain.MessageDomain, error)
+	GetMessagesByClientReferences(ctx context.Context, clientReferences []string) ([]*domain.MessageDomain, error)
+	ExpireMessages(ctx context.Context, now time.Time) (int64, error)
+	GetExpiredMessages(ctx context.Context, limit, offset int32) ([]*domain.MessageDomain, error)
+	GetExpiredMessagesAfterCursor(ctx context.Context, cursor *domain.MessageCursor, limit int32) ([]*domain.MessageDomain, error)
+	GetExpiredMessagesCount(ctx context.Context) (int64, error)
+	GetMessageByID(ctx context.Context, id int64) (*domain.MessageDomain, error)
+	CancelMessage(ctx context.Context, id int64) (bool, error)
+	CreateMessageAttempt(ctx context.Context, attempt *domain.MessageAttempt) error
+	GetMessageAttempts(ctx context.Context, messageID int64) ([]*domain.MessageAttempt, error)
+	SearchMessages(ctx context.Context, filter *domain.MessageFilter, limit, offset int32, cursor *domain.MessageCursor) ([]*domain.MessageDomain, error)
+	SearchMessagesCount(ctx context.Context, filter *domain.MessageFilter) (int64, error)
+	StreamMessages(ctx context.Context, filter *domain.MessageFilter, fn func(*domain.MessageDomain) error) error
+	RequeueMessage(ctx context.Context, id int64) (bool, error)
+	RequeueDeadMessages(ctx context.Context, filter *domain.MessageFilter) (int64, error)
+}
+
+// mysqlErrDuplicateEntry is the MySQL error number for unique key violations
+const mysqlErrDuplicateEntry = 1062
+
+// clientReferenceKey is the unique key guarding client_reference, the violated key is named in the error message
+const clientReferenceKey = "uk_messages_client_reference"
+
+func isDuplicateClientReferenceError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) &&
+		mysqlErr.Number == mysqlErrDuplicateEntry &&
+		strings.Contains(mysqlErr.Message, clientReferenceKey)
 }
 
 type Message struct {
 	Querier *db.Queries
+	DB      *sql.DB
 }
 
-func (m *Message) GetPendingMessages(ctx context.Context) ([]*domain.MessageDomain, error) {
-	rows, err := m.Querier.GetPendingMessage(ctx)
-	var messages []*domain.MessageDomain
+// ClaimPendingMessages locks up to limit due pending messages and failed messages that have attempts left
+// and whose retry time has arrived, oldest first, and marks them
+// as processing under the given owner until leaseExpiresAt. Rows already locked by another instance are
+// skipped so concurrent dispatchers never claim the same message.
+func (m *Message) ClaimPendingMessages(ctx context.Context, now time.Time, limit int32, maxAttempts int, owner string, leaseExpiresAt time.Time) ([]*domain.MessageDomain, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := m.Querier.WithTx(tx)
+	rows, err := qtx.GetPendingMessage(ctx, db.GetPendingMessageParams{
+		MaxAttempts: int32(maxAttempts),
+		Now:         sql.NullTime{Time: now, Valid: true},
+		Limit:       limit,
+	})
 	if err != nil {
 		return nil, err
 	}
+	if len(rows) == 0 {
+		return nil, tx.Commit()
+	}
+
+	messages := make([]*domain.MessageDomain, 0, len(rows))
+	ids := make([]int64, 0, len(rows))
 	for _, row := range rows {
 		message := dto.ConvertGetPendingMessageRowToMessageDomain(&row)
+		message.Status = domain.MessageStatusProcessing
 		messages = append(messages, message)
+		ids = append(ids, row.ID)
+	}
+
+	if _, err := qtx.ClaimMessages(ctx, db.ClaimMessagesParams{
+		LeaseOwner:     sql.NullString{String: owner, Valid: true},
+		LeaseExpiresAt: sql.NullTime{Time: leaseExpiresAt, Valid: true},
+		Ids:            ids,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
 	}
 	return messages, nil
 }
 
-func (m *Message) UpdateMessage(ctx context.Context, msg *domain.MessageDomain) error {
-	err := m.Querier.UpdateMessage(ctx, dto.ConvertUpdateMessageParamsToDomain(msg))
+// RecoverExpiredLeases returns messages whose claim expired before the owner finished sending them to
+// the failed status so they are retried, counting the interrupted send as an attempt. Messages that
+// reach maxAttempts this way are dead-lettered.
+func (m *Message) RecoverExpiredLeases(ctx context.Context, now time.Time, maxAttempts int) (int64, error) {
+	return m.Querier.RecoverExpiredLeases(ctx, db.RecoverExpiredLeasesParams{
+		MaxAttempts: int32(maxAttempts),
+		Now:         sql.NullTime{Time: now, Valid: true},
+	})
+}
+
+// DeadLetterExhaustedMessages moves failed messages that have no attempts left to the dead status
+func (m *Message) DeadLetterExhaustedMessages(ctx context.Context, maxAttempts int) (int64, error) {
+	return m.Querier.DeadLetterExhaustedMessages(ctx, int32(maxAttempts))
+}
+
+// ReleaseMessages hands claimed messages that were never sent back to the queue. Only claims still held
+// by owner are released.
+func (m *Message) ReleaseMessages(ctx context.Context, ids []int64, owner string) (int64, error) {
+	return m.Querier.ReleaseMessages(ctx, db.ReleaseMessagesParams{
+		LeaseOwner: sql.NullString{String: owner, Valid: true},
+		Ids:        ids,
+	})
+}
+
+// DeferMessage hands a claimed message back to the queue unsent, to be picked up again no earlier than
+// nextAttemptAt. Unlike a failed send it does not use up a retry attempt. Only a claim still held by
+// owner is deferred, it reports whether the row was deferred.
+func (m *Message) DeferMessage(ctx context.Context, id int64, nextAttemptAt time.Time, reason, owner string) (bool, error) {
+	affected, err := m.Querier.DeferMessage(ctx, dto.ConvertDeferMessageToParams(id, nextAttemptAt, reason, owner))
 	if err != nil {
-		return err
+		return false, err
 	}
-	return nil
+	return affected > 0, nil
+}
+
+// UpdateMessage records the outcome of a claimed message and ends the claim. Only a claim still held by
+// owner is updated, once the lease ran out the message may belong to another instance. It reports
+// whether the row was updated.
+func (m *Message) UpdateMessage(ctx context.Context, msg *domain.MessageDomain, owner string) (bool, error) {
+	affected, err := m.Querier.UpdateMessage(ctx, dto.ConvertUpdateMessageParamsToDomain(msg, owner))
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
 }
 
 func (m *Message) GetSentMessages(ctx context.Context, limit, offset int32) ([]*domain.MessageDomain, error) {
@@ -63,6 +178,20 @@ func (m *Message) GetSentMessages(ctx context.Context, limit, offset int32) ([]*
 	return messages, nil
 }
 
+// GetSentMessagesAfterCursor returns the sent messages that follow the cursor in (sent_at, id) order
+func (m *Message) GetSentMessagesAfterCursor(ctx context.Context, cursor *domain.MessageCursor, limit int32) ([]*domain.MessageDomain, error) {
+	rows, err := m.Querier.GetSentMessagesAfterCursor(ctx, dto.ConvertCursorToGetSentMessagesAfterCursorParams(cursor, limit))
+	var messages []*domain.MessageDomain
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		message := dto.ConvertGetSentMessagesAfterCursorRowToMessageDomain(&row)
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
 func (m *Message) GetSentMessagesCount(ctx context.Context) (int64, error) {
 	count, err := m.Querier.GetSentMessagesCount(ctx)
 	if err != nil {
@@ -70,3 +199,232 @@ func (m *Message) GetSentMessagesCount(ctx context.Context) (int64, error) {
 	}
 	return count, nil
 }
+
+func (m *Message) CreateMessage(ctx context.Context, msg *domain.MessageDomain) (int64, error) {
+	id, err := m.Querier.CreateMessage(ctx, dto.ConvertMessageDomainToCreateMessageParams(msg))
+	if isDuplicateClientReferenceError(err) {
+		return 0, domain.ErrDuplicateClientReference
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// createMessagesChunkSize bounds the rows of a single multi-row INSERT so large batches stay well below
+// the placeholder and packet limits of MySQL
+const createMessagesChunkSize = 500
+
+// CreateMessages inserts all messages in a single transaction, a chunk of rows per statement, and
+// returns their ids in input order. The ids are read back by the batch ref each row is tagged with.
+func (m *Message) CreateMessages(ctx context.Context, msgs []*domain.MessageDomain) ([]int64, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qtx := m.Querier.WithTx(tx)
+	token := utils.RandomToken()
+	ids := make([]int64, 0, len(msgs))
+	for start := 0; start < len(msgs); start += createMessagesChunkSize {
+		chunk := msgs[start:min(start+createMessagesChunkSize, len(msgs))]
+		params := make([]db.CreateMessageParams, 0, len(chunk))
+		refs := make([]sql.NullString, 0, len(chunk))
+		for i, msg := range chunk {
+			params = append(params, dto.ConvertMessageDomainToCreateMessageParams(msg))
+			refs = append(refs, batchRef(token, start+i))
+		}
+
+		err := insertMessages(ctx, tx, params, refs)
+		if isDuplicateClientReferenceError(err) {
+			return nil, domain.ErrDuplicateClientReference
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := qtx.GetMessageIDsByBatchRefs(ctx, refs)
+		if err != nil {
+			return nil, err
+		}
+		idByRef := make(map[string]int64, len(rows))
+		for _, row := range rows {
+			idByRef[row.BatchRef.String] = row.ID
+		}
+		for _, ref := range refs {
+			id, ok := idByRef[ref.String]
+			if !ok {
+				return nil, fmt.Errorf("inserted message %s was not found", ref.String)
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (m *Message) GetMessageByClientReference(ctx context.Context, clientReference string) (*domain.MessageDomain, error) {
+	row, err := m.Querier.GetMessageByClientReference(ctx, sql.NullString{String: clientReference, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dto.ConvertGetMessageByClientReferenceRowToMessageDomain(&row), nil
+}
+
+func (m *Message) GetMessagesByClientReferences(ctx context.Context, clientReferences []string) ([]*domain.MessageDomain, error) {
+	params := make([]sql.NullString, 0, len(clientReferences))
+	for _, clientReference := range clientReferences {
+		params = append(params, sql.NullString{String: clientReference, Valid: true})
+	}
+	rows, err := m.Querier.GetMessagesByClientReferences(ctx, params)
+	var messages []*domain.MessageDomain
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		message := dto.ConvertGetMessagesByClientReferencesRowToMessageDomain(&row)
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// ExpireMessages moves every unsent message whose expiry is at or before now to expired
+func (m *Message) ExpireMessages(ctx context.Context, now time.Time) (int64, error) {
+	count, err := m.Querier.ExpireMessages(ctx, sql.NullTime{Time: now, Valid: true})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (m *Message) GetExpiredMessages(ctx context.Context, limit, offset int32) ([]*domain.MessageDomain, error) {
+	rows, err := m.Querier.GetExpiredMessages(ctx, db.GetExpiredMessagesParams{
+		Limit:  limit,
+		Offset: offset,
+	})
+	var messages []*domain.MessageDomain
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		message := dto.ConvertGetExpiredMessageRowToMessageDomain(&row)
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// GetExpiredMessagesAfterCursor returns the expired messages that follow the cursor in (expires_at, id) order
+func (m *Message) GetExpiredMessagesAfterCursor(ctx context.Context, cursor *domain.MessageCursor, limit int32) ([]*domain.MessageDomain, error) {
+	rows, err := m.Querier.GetExpiredMessagesAfterCursor(ctx, dto.ConvertCursorToGetExpiredMessagesAfterCursorParams(cursor, limit))
+	var messages []*domain.MessageDomain
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		message := dto.ConvertGetExpiredMessagesAfterCursorRowToMessageDomain(&row)
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (m *Message) GetExpiredMessagesCount(ctx context.Context) (int64, error) {
+	count, err := m.Querier.GetExpiredMessagesCount(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (m *Message) GetMessageByID(ctx context.Context, id int64) (*domain.MessageDomain, error) {
+	row, err := m.Querier.GetMessageByID(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dto.ConvertMessageToMessageDomain(&row), nil
+}
+
+// CancelMessage cancels the message only while it is still pending or failed, it reports whether the row was cancelled
+func (m *Message) CancelMessage(ctx context.Context, id int64) (bool, error) {
+	count, err := m.Querier.CancelMessage(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (m *Message) CreateMessageAttempt(ctx context.Context, attempt *domain.MessageAttempt) error {
+	err := m.Querier.CreateMessageAttempt(ctx, dto.ConvertMessageAttemptDomainToCreateParams(attempt))
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (m *Message) GetMessageAttempts(ctx context.Context, messageID int64) ([]*domain.MessageAttempt, error) {
+	rows, err := m.Querier.GetMessageAttempts(ctx, messageID)
+	var attempts []*domain.MessageAttempt
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		attempt := dto.ConvertMessageAttemptToDomain(&row)
+		attempts = append(attempts, attempt)
+	}
+	return attempts, nil
+}
+
+// SearchMessages returns messages matching the filter, newest first. When cursor is set the
+// offset is ignored and only messages older than the cursor are returned.
+func (m *Message) SearchMessages(ctx context.Context, filter *domain.MessageFilter, limit, offset int32, cursor *domain.MessageCursor) ([]*domain.MessageDomain, error) {
+	rows, err := m.Querier.SearchMessages(ctx, dto.ConvertMessageFilterToSearchMessagesParams(filter, limit, offset, cursor))
+	var messages []*domain.MessageDomain
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		message := dto.ConvertMessageToMessageDomain(&row)
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (m *Message) SearchMessagesCount(ctx context.Context, filter *domain.MessageFilter) (int64, error) {
+	count, err := m.Querier.SearchMessagesCount(ctx, dto.ConvertMessageFilterToSearchMessagesCountParams(filter))
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// StreamMessages calls fn for every message matching the filter, oldest first, without loading
+// the whole result set into memory. Iteration stops at the first error returned by fn.
+func (m *Message) StreamMessages(ctx context.Context, filter *domain.MessageFilter, fn func(*domain.MessageDomain) error) error {
+	params := dto.ConvertMessageFilterToSearchMessagesCountParams(filter)
+	return streamMessages(ctx, dbdebug.Wrap(m.DB), params, func(row db.Message) error {
+		return fn(dto.ConvertMessageToMessageDomain(&row))
+	})
+}
+
+// RequeueMessage resets a dead-lettered message to pending with a fresh retry count, it reports whether the row was requeued
+func (m *Message) RequeueMessage(ctx context.Context, id int64) (bool, error) {
+	count, err := m.Querier.RequeueMessage(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RequeueDeadMessages resets every dead-lettered message matching the filter, the status filter is ignored
+func (m *Message) RequeueDeadMessages(ctx context.Context, filter *domain.MessageFilter) (int64, error) {
+	return m.Querier.RequeueDeadMessages(ctx, dto.ConvertMessageFilterToRequeueDeadMessagesParams(filter))
+}
@@ -0,0 +1,302 @@
+//go:build integration
+
+package message
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+)
+
+// These tests run the queries against a real MySQL. They are built with
+// -tags integration and need TEST_MYSQL_DSN pointing at a scratch database, whose tables are
+// recreated from models/dbConf/schema.sql, e.g.
+//
+//	TEST_MYSQL_DSN='root:secret@tcp(localhost:3306)/dispatcher_test?parseTime=true' go test -tags integration ./persistence/...
+
+const testMaxAttempts = 3
+
+func newTestPersistence(t *testing.T) (MessagePersistence, *sql.DB) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN is not set")
+	}
+	conn, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("failed to open MySQL: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	schema, err := os.ReadFile("../../models/dbConf/schema.sql")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	for _, table := range []string{"message_attempts", "messages"} {
+		if _, err := conn.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+			t.Fatalf("failed to drop %s: %v", table, err)
+		}
+	}
+	for _, statement := range strings.Split(string(schema), ";") {
+		if strings.TrimSpace(statement) == "" {
+			continue
+		}
+		if _, err := conn.Exec(statement); err != nil {
+			t.Fatalf("failed to create schema: %v", err)
+		}
+	}
+	return NewMessagePersistence(conn), conn
+}
+
+func TestCreateMessages(t *testing.T) {
+	p, conn := newTestPersistence(t)
+	ctx := context.Background()
+
+	// Rows inserted by other sessions in between must not shift the ids handed back
+	if _, err := p.CreateMessage(ctx, &domain.MessageDomain{RecipientPhone: "+905550000000", Content: "before"}); err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	count := createMessagesChunkSize + 3
+	msgs := make([]*domain.MessageDomain, 0, count)
+	for i := range count {
+		msgs = append(msgs, &domain.MessageDomain{RecipientPhone: "+905551111111", Content: strconv.Itoa(i)})
+	}
+	reference := "order-1"
+	msgs[1].ClientReference = &reference
+
+	ids, err := p.CreateMessages(ctx, msgs)
+	if err != nil {
+		t.Fatalf("CreateMessages error = %v", err)
+	}
+	if len(ids) != count {
+		t.Fatalf("got %d ids, want %d", len(ids), count)
+	}
+	for i, id := range ids {
+		var content string
+		if err := conn.QueryRow("SELECT content FROM messages WHERE id = ?", id).Scan(&content); err != nil {
+			t.Fatalf("failed to read message %d: %v", id, err)
+		}
+		if content != strconv.Itoa(i) {
+			t.Fatalf("id %d at position %d holds message %q", id, i, content)
+		}
+	}
+
+	if _, err := p.CreateMessages(ctx, []*domain.MessageDomain{{RecipientPhone: "+905551111111", Content: "again", ClientReference: &reference}}); !errors.Is(err, domain.ErrDuplicateClientReference) {
+		t.Fatalf("duplicate client reference error = %v, want ErrDuplicateClientReference", err)
+	}
+}
+
+// createTestMessage inserts a pending message and then applies set, an SQL SET clause, to move it
+// into the state a test needs
+func createTestMessage(t *testing.T, p MessagePersistence, conn *sql.DB, set string, args ...interface{}) int64 {
+	t.Helper()
+	id, err := p.CreateMessage(context.Background(), &domain.MessageDomain{RecipientPhone: "+905551111111", Content: "hello"})
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	if set != "" {
+		if _, err := conn.Exec("UPDATE messages SET "+set+" WHERE id = ?", append(args, id)...); err != nil {
+			t.Fatalf("failed to prepare message %d: %v", id, err)
+		}
+	}
+	return id
+}
+
+type leaseRow struct {
+	status     string
+	retryCount int
+	leaseOwner sql.NullString
+}
+
+func readLease(t *testing.T, conn *sql.DB, id int64) leaseRow {
+	t.Helper()
+	var row leaseRow
+	if err := conn.QueryRow("SELECT status, retry_count, lease_owner FROM messages WHERE id = ?", id).
+		Scan(&row.status, &row.retryCount, &row.leaseOwner); err != nil {
+		t.Fatalf("failed to read message %d: %v", id, err)
+	}
+	return row
+}
+
+func TestClaimPendingMessages(t *testing.T) {
+	p, conn := newTestPersistence(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	due := createTestMessage(t, p, conn, "")
+	retryDue := createTestMessage(t, p, conn, "status = 'failed', retry_count = 1, next_attempt_at = ?", now.Add(-time.Minute))
+	createTestMessage(t, p, conn, "scheduled_at = ?", now.Add(time.Hour))
+	createTestMessage(t, p, conn, "status = 'failed', retry_count = 1, next_attempt_at = ?", now.Add(time.Hour))
+	createTestMessage(t, p, conn, "status = 'failed', retry_count = ?", testMaxAttempts)
+	createTestMessage(t, p, conn, "status = 'processing', lease_owner = 'other', lease_expires_at = ?", now.Add(time.Minute))
+	dueLater := createTestMessage(t, p, conn, "")
+
+	tests := []struct {
+		name    string
+		owner   string
+		limit   int32
+		wantIDs []int64
+	}{
+		{name: "claims due messages oldest first up to the limit", owner: "replica-a", limit: 2, wantIDs: []int64{due, retryDue}},
+		{name: "a second claim does not get the same messages", owner: "replica-b", limit: 10, wantIDs: []int64{dueLater}},
+		{name: "nothing is left", owner: "replica-c", limit: 10, wantIDs: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claimed, err := p.ClaimPendingMessages(ctx, now, tt.limit, testMaxAttempts, tt.owner, now.Add(5*time.Minute))
+			if err != nil {
+				t.Fatalf("ClaimPendingMessages error = %v", err)
+			}
+			if len(claimed) != len(tt.wantIDs) {
+				t.Fatalf("claimed %d messages, want %d", len(claimed), len(tt.wantIDs))
+			}
+			for i, msg := range claimed {
+				if msg.ID != tt.wantIDs[i] {
+					t.Fatalf("claimed message %d at position %d, want %d", msg.ID, i, tt.wantIDs[i])
+				}
+				if row := readLease(t, conn, msg.ID); row.status != "processing" || row.leaseOwner.String != tt.owner {
+					t.Fatalf("message %d is %s owned by %q, want processing owned by %q", msg.ID, row.status, row.leaseOwner.String, tt.owner)
+				}
+			}
+		})
+	}
+}
+
+func TestLeaseOwnerGuards(t *testing.T) {
+	p, conn := newTestPersistence(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	const owner = "replica-a"
+
+	claimed := func(t *testing.T) int64 {
+		return createTestMessage(t, p, conn, "status = 'processing', lease_owner = ?, lease_expires_at = ?", owner, now.Add(time.Minute))
+	}
+	sent := domain.MessageStatusSent
+
+	tests := []struct {
+		name       string
+		owner      string
+		apply      func(id int64, owner string) (bool, error)
+		wantOK     bool
+		wantStatus string
+	}{
+		{
+			name:  "the owner records the outcome",
+			owner: owner,
+			apply: func(id int64, owner string) (bool, error) {
+				return p.UpdateMessage(ctx, &domain.MessageDomain{ID: id, Status: sent}, owner)
+			},
+			wantOK:     true,
+			wantStatus: "sent",
+		},
+		{
+			name:  "another replica cannot record an outcome",
+			owner: "replica-b",
+			apply: func(id int64, owner string) (bool, error) {
+				return p.UpdateMessage(ctx, &domain.MessageDomain{ID: id, Status: sent}, owner)
+			},
+			wantStatus: "processing",
+		},
+		{
+			name:  "the owner defers the message",
+			owner: owner,
+			apply: func(id int64, owner string) (bool, error) {
+				return p.DeferMessage(ctx, id, now.Add(time.Hour), "capped", owner)
+			},
+			wantOK:     true,
+			wantStatus: "pending",
+		},
+		{
+			name:  "another replica cannot defer the message",
+			owner: "replica-b",
+			apply: func(id int64, owner string) (bool, error) {
+				return p.DeferMessage(ctx, id, now.Add(time.Hour), "capped", owner)
+			},
+			wantStatus: "processing",
+		},
+		{
+			name:  "the owner hands the message back",
+			owner: owner,
+			apply: func(id int64, owner string) (bool, error) {
+				released, err := p.ReleaseMessages(ctx, []int64{id}, owner)
+				return released == 1, err
+			},
+			wantOK:     true,
+			wantStatus: "pending",
+		},
+		{
+			name:  "another replica cannot hand the message back",
+			owner: "replica-b",
+			apply: func(id int64, owner string) (bool, error) {
+				released, err := p.ReleaseMessages(ctx, []int64{id}, owner)
+				return released == 1, err
+			},
+			wantStatus: "processing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := claimed(t)
+			ok, err := tt.apply(id, tt.owner)
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if ok != tt.wantOK {
+				t.Fatalf("applied = %v, want %v", ok, tt.wantOK)
+			}
+			if row := readLease(t, conn, id); row.status != tt.wantStatus {
+				t.Fatalf("status = %s, want %s", row.status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRecoverExpiredLeases(t *testing.T) {
+	p, conn := newTestPersistence(t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	expired := createTestMessage(t, p, conn, "status = 'processing', lease_owner = 'gone', lease_expires_at = ?", now.Add(-time.Minute))
+	lastAttempt := createTestMessage(t, p, conn, "status = 'processing', retry_count = ?, lease_owner = 'gone', lease_expires_at = ?", testMaxAttempts-1, now.Add(-time.Minute))
+	active := createTestMessage(t, p, conn, "status = 'processing', lease_owner = 'alive', lease_expires_at = ?", now.Add(time.Minute))
+
+	recovered, err := p.RecoverExpiredLeases(context.Background(), now, testMaxAttempts)
+	if err != nil {
+		t.Fatalf("RecoverExpiredLeases error = %v", err)
+	}
+	if recovered != 2 {
+		t.Fatalf("recovered %d leases, want 2", recovered)
+	}
+
+	tests := []struct {
+		name           string
+		id             int64
+		wantStatus     string
+		wantRetryCount int
+		wantOwner      bool
+	}{
+		{name: "an expired lease is retried", id: expired, wantStatus: "failed", wantRetryCount: 1},
+		{name: "an expired lease on the last attempt is dead-lettered", id: lastAttempt, wantStatus: "dead", wantRetryCount: testMaxAttempts},
+		{name: "a live lease is left alone", id: active, wantStatus: "processing", wantOwner: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := readLease(t, conn, tt.id)
+			if row.status != tt.wantStatus || row.retryCount != tt.wantRetryCount || row.leaseOwner.Valid != tt.wantOwner {
+				t.Fatalf("message is %s with %d retries and owner %v, want %s with %d retries and owner %v",
+					row.status, row.retryCount, row.leaseOwner.Valid, tt.wantStatus, tt.wantRetryCount, tt.wantOwner)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package message
+
+import (
+	"context"
+
+	"github.com/smitendu1997/auto-message-dispatcher/models/db"
+)
+
+// sqlc only generates queries that collect every row into a slice, streamMessages
+// hands each row to a callback as soon as it is scanned so large result sets
+// never have to be held in memory. sqlc does not see this query, so it lists its
+// columns explicitly and keeps its scan list right next to them.
+
+const streamMessagesQuery = `-- StreamMessages
+SELECT id, recipient_phone, content, status, messageId, sent_at, retry_count, createdOn, updatedOn, client_reference, scheduled_at, expires_at, lease_owner, lease_expires_at, next_attempt_at, last_error FROM messages
+WHERE (? IS NULL OR status = ?)
+AND (? IS NULL OR recipient_phone = ?)
+AND (? IS NULL OR createdOn >= ?)
+AND (? IS NULL OR createdOn < ?)
+AND (? IS NULL OR content LIKE ?)
+ORDER BY id ASC
+`
+
+// streamMessages calls fn for every message matching the search filters, oldest first.
+// Iteration stops at the first error returned by fn.
+func streamMessages(ctx context.Context, conn db.DBTX, arg db.SearchMessagesCountParams, fn func(db.Message) error) error {
+	rows, err := conn.QueryContext(ctx, streamMessagesQuery,
+		arg.Status,
+		arg.Status,
+		arg.RecipientPhone,
+		arg.RecipientPhone,
+		arg.CreatedFrom,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.CreatedTo,
+		arg.ContentPattern,
+		arg.ContentPattern,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var i db.Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.RecipientPhone,
+			&i.Content,
+			&i.Status,
+			&i.Messageid,
+			&i.SentAt,
+			&i.RetryCount,
+			&i.Createdon,
+			&i.Updatedon,
+			&i.ClientReference,
+			&i.ScheduledAt,
+			&i.ExpiresAt,
+			&i.LeaseOwner,
+			&i.LeaseExpiresAt,
+			&i.NextAttemptAt,
+			&i.LastError,
+		); err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
@@ -2,30 +2,134 @@ package messaging
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/smitendu1997/auto-message-dispatcher/domain"
 	"github.com/smitendu1997/auto-message-dispatcher/persistence/message"
+	"github.com/smitendu1997/auto-message-dispatcher/utils"
 	"github.com/smitendu1997/auto-message-dispatcher/utils/redis"
 )
 
-func NewMessagingSvc(Gateways MessagingGateway, MessagingPersistence message.MessagePersistence, Redis *redis.RedisClient) MessagingSvcDriver {
+func NewMessagingSvc(Gateways MessagingGateway, MessagingPersistence message.MessagePersistence, Redis *redis.RedisClient, Dispatch DispatchConfig) MessagingSvcDriver {
 
 	// Persistence Declarations
+	Dispatch = Dispatch.withDefaults()
+
+	// Share the rate limit between replicas through Redis when it is available
+	var limiter RateLimiter = newLocalRateLimiter(Dispatch.RateLimit)
+	if Redis != nil {
+		limiter = newRedisRateLimiter(Redis, Dispatch.RateLimit, Dispatch.RateLimitBurst, Dispatch.RateLimitFallback, Dispatch.Replicas)
+	}
+
 	return &MessagingSvc{
 		MessagingPersistence: MessagingPersistence,
 		Gateways:             Gateways,
 		Redis:                Redis,
+		Dispatch:             Dispatch,
+		settings: domain.DispatchSettings{
+			BatchSize: Dispatch.BatchSize,
+			Workers:   Dispatch.Workers,
+			RateLimit: Dispatch.RateLimit,
+		},
+		limiter: limiter,
 	}
 
 }
 
+// DispatchConfig holds the dispatch settings. BatchSize, Workers and RateLimit (messages per second
+// across all replicas, 0 is unlimited) are starting values that UpdateDispatchSettings can change.
+// While Redis is down each replica sends at most RateLimitFallback, or RateLimit / Replicas when 0.
+type DispatchConfig struct {
+	BatchSize          int
+	Workers            int
+	RateLimit          float64
+	RateLimitBurst     float64
+	RateLimitFallback  float64
+	Replicas           int
+	SendTimeout        time.Duration
+	FrequencyCaps      []domain.FrequencyCapRule
+	FrequencyCapAction domain.FrequencyCapAction
+	InstanceID         string
+	LeaseDuration      time.Duration
+	Retry              domain.RetryPolicy
+}
+
+const (
+	defaultDispatchBatchSize     = 100
+	defaultDispatchWorkers       = 10
+	defaultDispatchSendTimeout   = 30 * time.Second
+	defaultDispatchLeaseDuration = 6 * time.Minute
+	defaultRetryBaseDelay        = 30 * time.Second
+	defaultRetryMultiplier       = 2
+	defaultRetryMaxDelay         = time.Hour
+	defaultRetryMaxAttempts      = 5
+)
+
+func (d DispatchConfig) withDefaults() DispatchConfig {
+	if d.BatchSize <= 0 {
+		d.BatchSize = defaultDispatchBatchSize
+	}
+	if d.Workers <= 0 {
+		d.Workers = defaultDispatchWorkers
+	}
+	if !d.FrequencyCapAction.IsValid() {
+		d.FrequencyCapAction = domain.FrequencyCapActionDefer
+	}
+	if d.SendTimeout <= 0 {
+		d.SendTimeout = defaultDispatchSendTimeout
+	}
+	if d.InstanceID == "" {
+		d.InstanceID = utils.DefaultInstanceID()
+	}
+	d.RateLimit = max(d.RateLimit, 0)
+	d.RateLimitBurst = max(d.RateLimitBurst, 0)
+	d.RateLimitFallback = max(d.RateLimitFallback, 0)
+	d.Replicas = max(d.Replicas, 1)
+	if d.LeaseDuration <= 0 {
+		d.LeaseDuration = defaultDispatchLeaseDuration
+	}
+	if d.Retry.BaseDelay <= 0 {
+		d.Retry.BaseDelay = defaultRetryBaseDelay
+	}
+	if d.Retry.Multiplier < 1 {
+		d.Retry.Multiplier = defaultRetryMultiplier
+	}
+	if d.Retry.MaxDelay <= 0 {
+		d.Retry.MaxDelay = defaultRetryMaxDelay
+	}
+	if d.Retry.MaxAttempts <= 0 {
+		d.Retry.MaxAttempts = defaultRetryMaxAttempts
+	}
+	d.Retry.Jitter = min(max(d.Retry.Jitter, 0), 1)
+	return d
+}
+
 type MessagingSvcDriver interface {
-	PollAndProcessMessages(ctx context.Context)
-	ListSentMessages(ctx context.Context, limit, offset int32) ([]*domain.MessageDomain, int64, bool, error)
+	PollAndProcessMessages(ctx context.Context) domain.PollSummary
+	ListSentMessages(ctx context.Context, page domain.PageRequest) (*domain.MessagePage, error)
+	ListExpiredMessages(ctx context.Context, page domain.PageRequest) (*domain.MessagePage, error)
+	CreateMessage(ctx context.Context, msg *domain.MessageDomain) (*domain.CreatedMessage, error)
+	CreateMessages(ctx context.Context, msgs []*domain.MessageDomain) ([]*domain.CreatedMessage, error)
+	CancelMessage(ctx context.Context, id int64) (*domain.MessageDomain, error)
+	GetMessage(ctx context.Context, id int64) (*domain.MessageDomain, []*domain.MessageAttempt, error)
+	SearchMessages(ctx context.Context, filter *domain.MessageFilter, page domain.PageRequest) (*domain.MessagePage, error)
+	ExportMessages(ctx context.Context, filter *domain.MessageFilter, fn func(*domain.MessageDomain) error) error
+	ListDeadMessages(ctx context.Context, page domain.PageRequest) (*domain.MessagePage, error)
+	RequeueMessage(ctx context.Context, id int64) (*domain.MessageDomain, error)
+	RequeueMessages(ctx context.Context, filter *domain.MessageFilter) (int64, error)
+	DispatchSettings() domain.DispatchSettings
+	UpdateDispatchSettings(settings domain.DispatchSettings) (domain.DispatchSettings, error)
+	RateLimitStats() domain.RateLimitStats
 }
 
 type MessagingSvc struct {
 	MessagingPersistence message.MessagePersistence
 	Gateways             MessagingGateway
 	Redis                *redis.RedisClient
+	Dispatch             DispatchConfig
+
+	settingsMu sync.RWMutex
+	settings   domain.DispatchSettings
+	limiter    RateLimiter
 }
@@ -0,0 +1,128 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+	"github.com/smitendu1997/auto-message-dispatcher/logger"
+	"github.com/spf13/cast"
+)
+
+// frequencyCapKeyPrefix is followed by the recipient phone, each key holds the recent sends to that number
+const frequencyCapKeyPrefix = "messaging:freqcap:"
+
+// checkFrequencyCapScript keeps a log of recent sends per recipient in a sorted set scored by send
+// time. ARGV[1] is the message id followed by limit and window (ms) pairs, one per rule. When a rule
+// is already at its limit it returns the rule's 1-based position and the milliseconds until its
+// oldest counted send leaves the window, otherwise it records the send and returns {0, 0}. The
+// message id is the member, a message already recorded by an earlier attempt is let through without
+// being counted against itself.
+const checkFrequencyCapScript = `
+if redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	return {0, 0}
+end
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local longest = 0
+for i = 2, #ARGV, 2 do
+	local limit = tonumber(ARGV[i])
+	local window = tonumber(ARGV[i + 1])
+	longest = math.max(longest, window)
+	local count = redis.call("ZCOUNT", KEYS[1], "(" .. (now - window), "+inf")
+	if count >= limit then
+		local oldest = redis.call("ZRANGEBYSCORE", KEYS[1], "(" .. (now - window), "+inf", "WITHSCORES", "LIMIT", count - limit, 1)
+		return {i / 2, tonumber(oldest[2]) + window - now}
+	end
+end
+redis.call("ZADD", KEYS[1], now, ARGV[1])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", "(" .. (now - longest))
+redis.call("PEXPIRE", KEYS[1], longest)
+return {0, 0}`
+
+// frequencyCapHit is the rule a message would break and when the recipient may be messaged again
+type frequencyCapHit struct {
+	rule    domain.FrequencyCapRule
+	allowAt time.Time
+}
+
+func (h *frequencyCapHit) reason() string {
+	return fmt.Sprintf("frequency cap exceeded: at most %s to this recipient", h.rule)
+}
+
+// checkFrequencyCap counts the message against every cap of its recipient and returns the first rule it
+// would break, nil when it may be sent. The send is only counted when no rule is hit, and has to be
+// released with releaseFrequencyCap if the message then is not sent after all. When Redis cannot be
+// reached the message is let through, the caps limit spam but must not stop delivery.
+func (c *MessagingSvc) checkFrequencyCap(ctx context.Context, functionName string, msg *domain.MessageDomain) *frequencyCapHit {
+	rules := c.Dispatch.FrequencyCaps
+	if len(rules) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, 1+2*len(rules))
+	args = append(args, msg.ID)
+	for _, rule := range rules {
+		args = append(args, rule.Limit, rule.Window.Milliseconds())
+	}
+	res, err := c.Redis.Eval(ctx, checkFrequencyCapScript, []string{frequencyCapKeyPrefix + msg.RecipientPhone}, args...)
+	if err != nil {
+		logger.Error(functionName, "failed_to_check_frequency_cap", msg.ID, err)
+		return nil
+	}
+
+	reply, ok := res.([]interface{})
+	if !ok || len(reply) != 2 {
+		logger.Error(functionName, "unexpected_frequency_cap_reply", msg.ID, res)
+		return nil
+	}
+	ruleIndex := cast.ToInt(reply[0])
+	if ruleIndex < 1 || ruleIndex > len(rules) {
+		return nil
+	}
+	retryAfter := time.Duration(cast.ToInt64(reply[1])) * time.Millisecond
+	return &frequencyCapHit{rule: rules[ruleIndex-1], allowAt: time.Now().Add(retryAfter)}
+}
+
+// releaseFrequencyCap takes back the send counted by checkFrequencyCap for a message that was not
+// delivered, so that failed, refused and held back sends do not use up the recipient's quota
+func (c *MessagingSvc) releaseFrequencyCap(ctx context.Context, functionName string, msg domain.MessageDomain) {
+	if len(c.Dispatch.FrequencyCaps) == 0 {
+		return
+	}
+	if _, err := c.Redis.Eval(ctx, releaseFrequencyCapScript, []string{frequencyCapKeyPrefix + msg.RecipientPhone}, msg.ID); err != nil {
+		logger.Error(functionName, "failed_to_release_frequency_cap", msg.ID, err)
+	}
+}
+
+// releaseFrequencyCapScript removes the message from its recipient's log of recent sends
+const releaseFrequencyCapScript = `return redis.call("ZREM", KEYS[1], ARGV[1])`
+
+// handleCappedMessage defers or rejects a message whose recipient reached a frequency cap, recording the
+// rule that was hit as the message's last error
+func (c *MessagingSvc) handleCappedMessage(ctx context.Context, functionName string, msg domain.MessageDomain, hit *frequencyCapHit) {
+	reason := hit.reason()
+
+	if c.Dispatch.FrequencyCapAction == domain.FrequencyCapActionReject {
+		logger.Info(functionName, "message_rejected_by_frequency_cap", msg.ID, "rule", hit.rule.String())
+		updateParams := domain.MessageDomain{
+			ID:        msg.ID,
+			Status:    domain.MessageStatusCapped,
+			LastError: &reason,
+		}
+		c.recordOutcome(ctx, functionName, &updateParams, "failed_to_update_capped_message_status")
+		return
+	}
+
+	logger.Info(functionName, "message_deferred_by_frequency_cap", msg.ID, "rule", hit.rule.String(),
+		"next_attempt_at", hit.allowAt.Format(time.RFC3339))
+	deferred, err := c.MessagingPersistence.DeferMessage(ctx, msg.ID, hit.allowAt, reason, c.Dispatch.InstanceID)
+	if err != nil {
+		logger.Error(functionName, "failed_to_defer_capped_message", err)
+		return
+	}
+	if !deferred {
+		logger.Error(functionName, "message_lease_lost", msg.ID, "status", domain.MessageStatusPending)
+	}
+}
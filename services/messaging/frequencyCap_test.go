@@ -0,0 +1,93 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+)
+
+func TestCheckFrequencyCap(t *testing.T) {
+	hourly := domain.FrequencyCapRule{Limit: 2, Window: time.Hour}
+	daily := domain.FrequencyCapRule{Limit: 3, Window: 24 * time.Hour}
+
+	type step struct {
+		// release takes the message's send back instead of checking it
+		release bool
+		id      int64
+		// wantRule is the rule hit, nil when the message may be sent
+		wantRule *domain.FrequencyCapRule
+	}
+	tests := []struct {
+		name  string
+		rules []domain.FrequencyCapRule
+		steps []step
+	}{
+		{
+			name:  "sends within the limit pass",
+			rules: []domain.FrequencyCapRule{hourly},
+			steps: []step{{id: 1}, {id: 2}},
+		},
+		{
+			name:  "the send over the limit is capped",
+			rules: []domain.FrequencyCapRule{hourly},
+			steps: []step{{id: 1}, {id: 2}, {id: 3, wantRule: &hourly}},
+		},
+		{
+			name:  "a retried message does not count against itself",
+			rules: []domain.FrequencyCapRule{hourly},
+			steps: []step{{id: 1}, {id: 2}, {id: 2}, {id: 3, wantRule: &hourly}},
+		},
+		{
+			name:  "a released send frees its place",
+			rules: []domain.FrequencyCapRule{hourly},
+			steps: []step{{id: 1}, {id: 2}, {release: true, id: 2}, {id: 3}, {id: 4, wantRule: &hourly}},
+		},
+		{
+			name:  "a capped message is not counted",
+			rules: []domain.FrequencyCapRule{hourly},
+			steps: []step{{id: 1}, {id: 2}, {id: 3, wantRule: &hourly}, {release: true, id: 1}, {id: 4}},
+		},
+		{
+			name:  "the first rule hit is reported",
+			rules: []domain.FrequencyCapRule{{Limit: 5, Window: time.Hour}, daily},
+			steps: []step{{id: 1}, {id: 2}, {id: 3}, {id: 4, wantRule: &daily}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestRedis(t)
+			phone := "test:" + t.Name()
+			testKey(t, client, frequencyCapKeyPrefix+phone)
+			svc := &MessagingSvc{Redis: client, Dispatch: DispatchConfig{FrequencyCaps: tt.rules}}
+
+			for i, s := range tt.steps {
+				msg := &domain.MessageDomain{ID: s.id, RecipientPhone: phone}
+				if s.release {
+					svc.releaseFrequencyCap(context.Background(), t.Name(), *msg)
+					continue
+				}
+				hit := svc.checkFrequencyCap(context.Background(), t.Name(), msg)
+				switch {
+				case s.wantRule == nil && hit != nil:
+					t.Fatalf("step %d: message %d capped by %s, want it sent", i+1, s.id, hit.rule)
+				case s.wantRule != nil && hit == nil:
+					t.Fatalf("step %d: message %d sent, want it capped by %s", i+1, s.id, s.wantRule)
+				case s.wantRule != nil && hit.rule != *s.wantRule:
+					t.Fatalf("step %d: message %d capped by %s, want %s", i+1, s.id, hit.rule, s.wantRule)
+				case hit != nil && (hit.allowAt.Before(time.Now()) || hit.allowAt.After(time.Now().Add(hit.rule.Window))):
+					t.Fatalf("step %d: allowed again at %s, want within %s", i+1, hit.allowAt, hit.rule.Window)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckFrequencyCapWithoutRules(t *testing.T) {
+	svc := &MessagingSvc{}
+	if hit := svc.checkFrequencyCap(context.Background(), t.Name(), &domain.MessageDomain{ID: 1}); hit != nil {
+		t.Fatalf("message capped by %s without any rules", hit.rule)
+	}
+}
@@ -0,0 +1,158 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+	"github.com/smitendu1997/auto-message-dispatcher/logger"
+)
+
+// CreateMessage inserts a pending message. When the message carries a client reference that
+// was already used for the same message, the current state of the original message is returned
+// instead of inserting a new row. A reference reused for a different message is rejected with
+// domain.ErrClientReferenceReused.
+func (c *MessagingSvc) CreateMessage(ctx context.Context, msg *domain.MessageDomain) (*domain.CreatedMessage, error) {
+	const functionName = "messaging.MessagingSvc.CreateMessage"
+
+	if msg.ClientReference != nil {
+		existing, err := c.findMessageByClientReference(ctx, *msg.ClientReference)
+		if err != nil {
+			logger.Error(functionName, "failed_to_lookup_client_reference", err)
+			return nil, err
+		}
+		if existing != nil {
+			return c.replayMessage(ctx, functionName, msg, existing)
+		}
+	}
+
+	id, err := c.MessagingPersistence.CreateMessage(ctx, msg)
+	if errors.Is(err, domain.ErrDuplicateClientReference) {
+		// A concurrent request with the same reference won the insert
+		existing, lookupErr := c.MessagingPersistence.GetMessageByClientReference(ctx, *msg.ClientReference)
+		if lookupErr != nil {
+			logger.Error(functionName, "failed_to_lookup_client_reference", lookupErr)
+			return nil, lookupErr
+		}
+		return c.replayMessage(ctx, functionName, msg, existing)
+	}
+	if err != nil {
+		logger.Error(functionName, "failed_to_create_message", err)
+		return nil, err
+	}
+
+	logger.Info(functionName, "message_created_successfully", "id", id)
+
+	return &domain.CreatedMessage{Message: newPendingMessage(id, msg)}, nil
+}
+
+func (c *MessagingSvc) replayMessage(ctx context.Context, functionName string, msg, existing *domain.MessageDomain) (*domain.CreatedMessage, error) {
+	if !existing.SamePayload(msg) {
+		logger.Error(functionName, "client_reference_reused", "id", existing.ID)
+		return nil, domain.ErrClientReferenceReused
+	}
+	logger.Info(functionName, "message_replayed", "id", existing.ID)
+	return &domain.CreatedMessage{Message: existing, Replayed: true}, nil
+}
+
+// CreateMessages inserts a batch of pending messages in one transaction. Messages whose client
+// reference already exists, or repeats an earlier entry of the same batch, are replayed rather
+// than inserted, unless the reference was used for a different message, in which case the entry
+// carries domain.ErrClientReferenceReused. The result is aligned with msgs.
+func (c *MessagingSvc) CreateMessages(ctx context.Context, msgs []*domain.MessageDomain) ([]*domain.CreatedMessage, error) {
+	const functionName = "messaging.MessagingSvc.CreateMessages"
+
+	results := make([]*domain.CreatedMessage, len(msgs))
+
+	// Resolve references that already exist in the database
+	var clientReferences []string
+	for _, msg := range msgs {
+		if msg.ClientReference != nil {
+			clientReferences = append(clientReferences, *msg.ClientReference)
+		}
+	}
+	existingByReference := make(map[string]*domain.MessageDomain)
+	if len(clientReferences) > 0 {
+		existing, err := c.MessagingPersistence.GetMessagesByClientReferences(ctx, clientReferences)
+		if err != nil {
+			logger.Error(functionName, "failed_to_lookup_client_references", err)
+			return nil, err
+		}
+		for _, msg := range existing {
+			existingByReference[*msg.ClientReference] = msg
+		}
+	}
+
+	// Only the first occurrence of a new reference is inserted, repeats point back to it
+	var toInsert []*domain.MessageDomain
+	var insertIndexes []int
+	firstIndexByReference := make(map[string]int)
+	var repeatIndexes []int
+	for i, msg := range msgs {
+		if msg.ClientReference != nil {
+			if existing, ok := existingByReference[*msg.ClientReference]; ok {
+				results[i] = replayedMessage(msg, existing)
+				continue
+			}
+			if _, ok := firstIndexByReference[*msg.ClientReference]; ok {
+				repeatIndexes = append(repeatIndexes, i)
+				continue
+			}
+			firstIndexByReference[*msg.ClientReference] = i
+		}
+		toInsert = append(toInsert, msg)
+		insertIndexes = append(insertIndexes, i)
+	}
+
+	if len(toInsert) > 0 {
+		ids, err := c.MessagingPersistence.CreateMessages(ctx, toInsert)
+		if err != nil {
+			logger.Error(functionName, "failed_to_create_messages", err)
+			return nil, err
+		}
+		for i, id := range ids {
+			results[insertIndexes[i]] = &domain.CreatedMessage{Message: newPendingMessage(id, toInsert[i])}
+		}
+	}
+
+	for _, i := range repeatIndexes {
+		first := results[firstIndexByReference[*msgs[i].ClientReference]]
+		results[i] = replayedMessage(msgs[i], first.Message)
+	}
+
+	logger.Info(functionName, "messages_created_successfully", "inserted", len(toInsert), "replayed", len(msgs)-len(toInsert))
+
+	return results, nil
+}
+
+func replayedMessage(msg, existing *domain.MessageDomain) *domain.CreatedMessage {
+	if !existing.SamePayload(msg) {
+		return &domain.CreatedMessage{Err: domain.ErrClientReferenceReused}
+	}
+	return &domain.CreatedMessage{Message: existing, Replayed: true}
+}
+
+func newPendingMessage(id int64, msg *domain.MessageDomain) *domain.MessageDomain {
+	return &domain.MessageDomain{
+		ID:              id,
+		RecipientPhone:  msg.RecipientPhone,
+		Content:         msg.Content,
+		Status:          domain.MessageStatusPending,
+		ClientReference: msg.ClientReference,
+		ScheduledAt:     msg.ScheduledAt,
+		ExpiresAt:       msg.ExpiresAt,
+	}
+}
+
+// findMessageByClientReference returns the current row for the reference, or nil when the
+// reference has not been used yet. The unique index on client_reference backs this lookup.
+func (c *MessagingSvc) findMessageByClientReference(ctx context.Context, clientReference string) (*domain.MessageDomain, error) {
+	existing, err := c.MessagingPersistence.GetMessageByClientReference(ctx, clientReference)
+	if errors.Is(err, domain.ErrMessageNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
@@ -0,0 +1,176 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+	"github.com/smitendu1997/auto-message-dispatcher/persistence/message"
+)
+
+// fakeCreationPersistence keeps messages by client reference in memory. Calls outside message
+// creation are not implemented and panic through the embedded nil interface.
+type fakeCreationPersistence struct {
+	message.MessagePersistence
+	byReference map[string]*domain.MessageDomain
+	nextID      int64
+	// raceReference is inserted by "another request" right before the next insert that uses it
+	raceReference *domain.MessageDomain
+}
+
+func newFakeCreationPersistence(existing ...*domain.MessageDomain) *fakeCreationPersistence {
+	p := &fakeCreationPersistence{byReference: make(map[string]*domain.MessageDomain), nextID: 100}
+	for _, msg := range existing {
+		p.byReference[*msg.ClientReference] = msg
+	}
+	return p
+}
+
+func (p *fakeCreationPersistence) GetMessageByClientReference(ctx context.Context, clientReference string) (*domain.MessageDomain, error) {
+	if msg, ok := p.byReference[clientReference]; ok {
+		return msg, nil
+	}
+	return nil, domain.ErrMessageNotFound
+}
+
+func (p *fakeCreationPersistence) GetMessagesByClientReferences(ctx context.Context, clientReferences []string) ([]*domain.MessageDomain, error) {
+	var msgs []*domain.MessageDomain
+	for _, reference := range clientReferences {
+		if msg, ok := p.byReference[reference]; ok {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, nil
+}
+
+func (p *fakeCreationPersistence) CreateMessage(ctx context.Context, msg *domain.MessageDomain) (int64, error) {
+	if race := p.raceReference; race != nil && msg.ClientReference != nil && *race.ClientReference == *msg.ClientReference {
+		p.byReference[*race.ClientReference] = race
+		p.raceReference = nil
+	}
+	if msg.ClientReference != nil {
+		if _, ok := p.byReference[*msg.ClientReference]; ok {
+			return 0, domain.ErrDuplicateClientReference
+		}
+	}
+	p.nextID++
+	if msg.ClientReference != nil {
+		stored := *msg
+		stored.ID = p.nextID
+		p.byReference[*msg.ClientReference] = &stored
+	}
+	return p.nextID, nil
+}
+
+func (p *fakeCreationPersistence) CreateMessages(ctx context.Context, msgs []*domain.MessageDomain) ([]int64, error) {
+	ids := make([]int64, 0, len(msgs))
+	for _, msg := range msgs {
+		id, err := p.CreateMessage(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func testMessage(reference, content string) *domain.MessageDomain {
+	msg := &domain.MessageDomain{RecipientPhone: "+905551111111", Content: content}
+	if reference != "" {
+		msg.ClientReference = &reference
+	}
+	return msg
+}
+
+func TestCreateMessageIdempotency(t *testing.T) {
+	original := testMessage("order-1", "hello")
+	original.ID = 7
+	original.Status = domain.MessageStatusSent
+
+	tests := []struct {
+		name         string
+		existing     []*domain.MessageDomain
+		race         *domain.MessageDomain
+		msg          *domain.MessageDomain
+		wantID       int64
+		wantReplayed bool
+		wantErr      error
+	}{
+		{name: "new reference is inserted", msg: testMessage("order-2", "hello"), wantID: 101},
+		{name: "no reference is inserted", msg: testMessage("", "hello"), wantID: 101},
+		{name: "same message replays the original", existing: []*domain.MessageDomain{original}, msg: testMessage("order-1", "hello"), wantID: 7, wantReplayed: true},
+		{name: "different message is rejected", existing: []*domain.MessageDomain{original}, msg: testMessage("order-1", "bye"), wantErr: domain.ErrClientReferenceReused},
+		{name: "concurrent insert of the same message replays it", race: original, msg: testMessage("order-1", "hello"), wantID: 7, wantReplayed: true},
+		{name: "concurrent insert of a different message is rejected", race: original, msg: testMessage("order-1", "bye"), wantErr: domain.ErrClientReferenceReused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			persistence := newFakeCreationPersistence(tt.existing...)
+			persistence.raceReference = tt.race
+			svc := &MessagingSvc{MessagingPersistence: persistence}
+
+			created, err := svc.CreateMessage(context.Background(), tt.msg)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if created.Message.ID != tt.wantID || created.Replayed != tt.wantReplayed {
+				t.Fatalf("created id %d replayed %v, want id %d replayed %v", created.Message.ID, created.Replayed, tt.wantID, tt.wantReplayed)
+			}
+		})
+	}
+}
+
+func TestCreateMessagesIdempotency(t *testing.T) {
+	original := testMessage("order-1", "hello")
+	original.ID = 7
+	persistence := newFakeCreationPersistence(original)
+	svc := &MessagingSvc{MessagingPersistence: persistence}
+
+	results, err := svc.CreateMessages(context.Background(), []*domain.MessageDomain{
+		testMessage("order-1", "hello"),
+		testMessage("order-1", "bye"),
+		testMessage("order-2", "new"),
+		testMessage("order-2", "new"),
+		testMessage("order-2", "changed"),
+		testMessage("", "plain"),
+	})
+	if err != nil {
+		t.Fatalf("error = %v", err)
+	}
+
+	want := []struct {
+		id       int64
+		replayed bool
+		err      error
+	}{
+		{id: 7, replayed: true},
+		{err: domain.ErrClientReferenceReused},
+		{id: 101},
+		{id: 101, replayed: true},
+		{err: domain.ErrClientReferenceReused},
+		{id: 102},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		got := results[i]
+		if w.err != nil {
+			if !errors.Is(got.Err, w.err) {
+				t.Fatalf("result %d error = %v, want %v", i, got.Err, w.err)
+			}
+			continue
+		}
+		if got.Err != nil || got.Message.ID != w.id || got.Replayed != w.replayed {
+			t.Fatalf("result %d = id %d replayed %v error %v, want id %d replayed %v", i, got.Message.ID, got.Replayed, got.Err, w.id, w.replayed)
+		}
+	}
+}
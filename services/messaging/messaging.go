@@ -3,6 +3,8 @@ package messaging
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/smitendu1997/auto-message-dispatcher/domain"
@@ -14,31 +16,318 @@ type MessagingGateway interface {
 	SendMessage(ctx context.Context, domainReq *domain.MessageDomain) (domain.MessageDomain, error)
 }
 
-func (c *MessagingSvc) PollAndProcessMessages(ctx context.Context) {
+// PollAndProcessMessages claims the due messages and sends them, every message is handled on its own so
+// one failing send never holds up the rest of the batch. It returns a summary of the poll.
+func (c *MessagingSvc) PollAndProcessMessages(ctx context.Context) domain.PollSummary {
 	const functionName = "messaging.MessagingSvc.pollAndProcessMessages"
-	messages, err := c.MessagingPersistence.GetPendingMessages(ctx)
+	now := time.Now()
+	// Settings may be changed while a poll runs, the new values apply from the next poll
+	settings := c.DispatchSettings()
+	// No more messages are claimed than the rate limit and the workers get through before their lease expires
+	claimLimit := settings.ClaimLimit(c.Dispatch.LeaseDuration, c.Dispatch.SendTimeout)
+	summary := domain.PollSummary{StartedAt: now, BatchSize: claimLimit}
+
+	// Expire stale messages first so they never reach the gateway
+	expiredCount, err := c.MessagingPersistence.ExpireMessages(ctx, now)
 	if err != nil {
-		logger.Error(functionName, "failed_to_get_pending_messages", err)
-		return
+		logger.Error(functionName, "failed_to_expire_messages", err)
+	} else if expiredCount > 0 {
+		logger.Info(functionName, "messages_expired", expiredCount)
 	}
-	for _, msg := range messages {
-		logger.Info(functionName, "processing_message", msg.ID)
-		// Check Redis cache first
-		cacheKey := "messageSent_" + cast.ToString(msg.ID)
-		if sentData, err := c.checkMessageCache(ctx, cacheKey); err == nil && sentData != nil {
-			c.handleAlreadySentMessage(ctx, functionName, *msg, sentData)
-			continue
+	summary.ExpiredBeforeClaim = expiredCount
+
+	// Release messages left in processing by an instance that stopped before finishing them
+	recoveredCount, err := c.MessagingPersistence.RecoverExpiredLeases(ctx, now, c.Dispatch.Retry.MaxAttempts)
+	if err != nil {
+		logger.Error(functionName, "failed_to_recover_expired_leases", err)
+	} else if recoveredCount > 0 {
+		logger.Info(functionName, "expired_leases_recovered", recoveredCount)
+	}
+	summary.RecoveredLeases = recoveredCount
+
+	// Dead-letter failed messages that have no attempts left, e.g. after max attempts was lowered
+	deadCount, err := c.MessagingPersistence.DeadLetterExhaustedMessages(ctx, c.Dispatch.Retry.MaxAttempts)
+	if err != nil {
+		logger.Error(functionName, "failed_to_dead_letter_exhausted_messages", err)
+	} else if deadCount > 0 {
+		logger.Info(functionName, "exhausted_messages_dead_lettered", deadCount)
+	}
+	summary.DeadLettered = deadCount
+
+	// Claim messages whose scheduled time has arrived so no other instance sends them
+	leaseExpiresAt := now.Add(c.Dispatch.LeaseDuration)
+	messages, err := c.MessagingPersistence.ClaimPendingMessages(ctx, now, int32(claimLimit), c.Dispatch.Retry.MaxAttempts, c.Dispatch.InstanceID, leaseExpiresAt)
+	if err != nil {
+		logger.Error(functionName, "failed_to_claim_pending_messages", err)
+		summary.Err = err
+		summary.Duration = time.Since(now)
+		return summary
+	}
+	summary.Claimed = len(messages)
+
+	var skippedIDs []int64
+	for i, result := range c.dispatchMessages(ctx, functionName, messages, settings.Workers, leaseExpiresAt) {
+		switch result.outcome {
+		case dispatchOutcomeSent:
+			summary.Sent++
+		case dispatchOutcomeFailed:
+			summary.Failed++
+		case dispatchOutcomePermanent:
+			summary.PermanentlyFailed++
+		case dispatchOutcomeExpired:
+			summary.Expired++
+		case dispatchOutcomeAlreadySent:
+			summary.AlreadySent++
+		case dispatchOutcomeCapped:
+			summary.Capped++
+		case dispatchOutcomeSkipped:
+			summary.Skipped++
+			skippedIDs = append(skippedIDs, messages[i].ID)
 		}
+		summary.SendDuration += result.sendDuration
+		summary.MaxSendDuration = max(summary.MaxSendDuration, result.sendDuration)
+	}
 
-		// Send message through gateway
-		response, err := c.Gateways.SendMessage(ctx, msg)
-		if err != nil {
-			c.handleFailedMessage(ctx, functionName, *msg)
-			return
+	// Give back the claims on messages that were never sent, because the poll was stopped or the lease
+	// ran short. The poll may be cancelled, so the release must not be tied to its context.
+	if len(skippedIDs) > 0 {
+		if _, err := c.MessagingPersistence.ReleaseMessages(context.WithoutCancel(ctx), skippedIDs, c.Dispatch.InstanceID); err != nil {
+			logger.Error(functionName, "failed_to_release_skipped_messages", err)
 		}
+	}
 
-		// Update message status based on response
-		c.updateMessageStatus(ctx, functionName, *msg, &response)
+	summary.Duration = time.Since(now)
+	if summary.Claimed > 0 {
+		logger.Info(functionName, "poll_completed", "claimed", summary.Claimed,
+			"sent", summary.Sent, "failed", summary.Failed, "permanently_failed", summary.PermanentlyFailed,
+			"expired", summary.Expired, "already_sent", summary.AlreadySent, "capped", summary.Capped, "skipped", summary.Skipped,
+			"duration_ms", summary.Duration.Milliseconds(), "send_duration_ms", summary.SendDuration.Milliseconds(),
+			"max_send_duration_ms", summary.MaxSendDuration.Milliseconds())
+	}
+	return summary
+}
+
+// dispatchOutcome is what happened to a single message during a poll
+type dispatchOutcome string
+
+const (
+	dispatchOutcomeSent        dispatchOutcome = "sent"
+	dispatchOutcomeFailed      dispatchOutcome = "failed"
+	dispatchOutcomePermanent   dispatchOutcome = "permanently_failed"
+	dispatchOutcomeExpired     dispatchOutcome = "expired"
+	dispatchOutcomeAlreadySent dispatchOutcome = "already_sent"
+	dispatchOutcomeSkipped     dispatchOutcome = "skipped"
+	dispatchOutcomeCapped      dispatchOutcome = "capped"
+)
+
+// dispatchResult is the outcome of a single message and how long its gateway call took
+type dispatchResult struct {
+	outcome      dispatchOutcome
+	sendDuration time.Duration
+}
+
+// dispatchMessages sends the messages on a bounded pool of workers and returns the result of each
+// message in input order. Messages that were not handed to a worker because the context was cancelled
+// are reported as skipped. The messages are claimed until leaseExpiresAt.
+func (c *MessagingSvc) dispatchMessages(ctx context.Context, functionName string, messages []*domain.MessageDomain, workers int, leaseExpiresAt time.Time) []dispatchResult {
+	results := make([]dispatchResult, len(messages))
+	for i := range results {
+		results[i].outcome = dispatchOutcomeSkipped
+	}
+
+	workers = min(workers, len(messages))
+
+	var (
+		wg   sync.WaitGroup
+		jobs = make(chan int)
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				// Each worker writes only its own index so no locking is needed
+				results[i] = c.safeProcessMessage(ctx, functionName, messages[i], leaseExpiresAt)
+			}
+		}()
+	}
+
+	for i := range messages {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// dispatchProgress records how far a message got before a panic, so the outcome can still be recorded
+// without sending the message twice
+type dispatchProgress struct {
+	// handedOver is set right before the message is handed to the gateway
+	handedOver bool
+	// accepted holds the gateway response once the provider accepted the message
+	accepted *domain.MessageDomain
+}
+
+// safeProcessMessage keeps a panic while handling one message from taking down the worker and the rest
+// of the batch, recording whatever outcome is known instead of leaving the message to lease recovery,
+// which would send it again.
+func (c *MessagingSvc) safeProcessMessage(ctx context.Context, functionName string, msg *domain.MessageDomain, leaseExpiresAt time.Time) (result dispatchResult) {
+	var progress dispatchProgress
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error(functionName, "panic_while_processing_message", msg.ID, r)
+			result = c.recoverMessage(context.WithoutCancel(ctx), functionName, *msg, &progress, r)
+		}
+	}()
+	return c.processMessage(ctx, functionName, msg, leaseExpiresAt, &progress)
+}
+
+// errPanicAfterHandover is recorded when handling a message panicked after it was given to the gateway
+const errPanicAfterHandover = "dispatcher panicked after handing the message to the messaging service, check with the provider before requeueing"
+
+// recoverMessage records the outcome of a message whose handling panicked. An accepted message is marked
+// sent, one that may have reached the provider is dead-lettered for review and one that never left is
+// retried like a failed send. Only plain status writes are made here, a second panic would take down the
+// worker. Outcomes recorded before the panic are left alone since the claim is already released.
+func (c *MessagingSvc) recoverMessage(ctx context.Context, functionName string, msg domain.MessageDomain, progress *dispatchProgress, r interface{}) dispatchResult {
+	switch {
+	case progress.accepted != nil:
+		updateParams := domain.MessageDomain{
+			ID:        msg.ID,
+			Status:    domain.MessageStatusSent,
+			MessageID: progress.accepted.MessageID,
+			SentAt:    progress.accepted.SentAt,
+			LastError: progress.accepted.LastError,
+		}
+		c.recordOutcome(ctx, functionName, &updateParams, "failed_to_update_message_status")
+		return dispatchResult{outcome: dispatchOutcomeSent}
+	case progress.handedOver:
+		reason := errPanicAfterHandover
+		updateParams := domain.MessageDomain{
+			ID:        msg.ID,
+			Status:    domain.MessageStatusDead,
+			LastError: &reason,
+		}
+		c.recordOutcome(ctx, functionName, &updateParams, "failed_to_update_permanently_failed_message_status")
+		return dispatchResult{outcome: dispatchOutcomePermanent}
+	default:
+		c.releaseFrequencyCap(ctx, functionName, msg)
+		c.handleFailedMessage(ctx, functionName, msg, fmt.Sprintf("dispatcher panicked before sending: %v", r))
+		return dispatchResult{outcome: dispatchOutcomeFailed}
+	}
+}
+
+// processMessage sends a single message and persists the result. Once the rate limit lets a message
+// through it is seen through even if the poll is cancelled: aborting a request the provider may already
+// have accepted, or failing to record its outcome, is what causes duplicate sends. The gateway call is
+// bounded by SendTimeout instead, and a message is only sent while that still ends within its lease at
+// leaseExpiresAt. progress is kept up to date for safeProcessMessage.
+func (c *MessagingSvc) processMessage(ctx context.Context, functionName string, msg *domain.MessageDomain, leaseExpiresAt time.Time, progress *dispatchProgress) dispatchResult {
+	pollCtx := ctx
+	ctx = context.WithoutCancel(ctx)
+	logger.Info(functionName, "processing_message", msg.ID)
+	if msg.IsExpired(time.Now()) {
+		c.handleExpiredMessage(ctx, functionName, *msg)
+		return dispatchResult{outcome: dispatchOutcomeExpired}
+	}
+
+	// Check Redis cache first
+	cacheKey := "messageSent_" + cast.ToString(msg.ID)
+	if sentData, err := c.checkMessageCache(ctx, cacheKey); err == nil && sentData != nil {
+		c.handleAlreadySentMessage(ctx, functionName, *msg, sentData)
+		return dispatchResult{outcome: dispatchOutcomeAlreadySent}
+	}
+
+	// Keep a single recipient from being flooded
+	if hit := c.checkFrequencyCap(ctx, functionName, msg); hit != nil {
+		c.handleCappedMessage(ctx, functionName, *msg, hit)
+		return dispatchResult{outcome: dispatchOutcomeCapped}
+	}
+
+	// Wait for the rate limit, a message still waiting when the poll is stopped is handed back unsent
+	if err := c.limiter.Wait(pollCtx); err != nil {
+		c.releaseFrequencyCap(ctx, functionName, *msg)
+		return dispatchResult{outcome: dispatchOutcomeSkipped}
+	}
+
+	// A send still running when the lease runs out could overlap with another instance claiming the
+	// message, e.g. after waiting long for a rate limit shared with other replicas. Hand it back instead.
+	if time.Now().Add(c.Dispatch.SendTimeout).After(leaseExpiresAt) {
+		logger.Info(functionName, "lease_too_short_to_send", msg.ID)
+		c.releaseFrequencyCap(ctx, functionName, *msg)
+		return dispatchResult{outcome: dispatchOutcomeSkipped}
+	}
+
+	// Send message through gateway and keep a record of the attempt
+	attemptedAt := time.Now()
+	sendCtx, cancel := context.WithTimeout(ctx, c.Dispatch.SendTimeout)
+	progress.handedOver = true
+	response, err := c.Gateways.SendMessage(sendCtx, msg)
+	cancel()
+	if err == nil && response.Status == domain.MessageStatusSent {
+		progress.accepted = &response
+	}
+	result := dispatchResult{sendDuration: time.Since(attemptedAt)}
+	c.recordAttempt(ctx, functionName, msg.ID, attemptedAt, &response, err)
+	// Only a send the provider may have delivered keeps counting against the recipient's caps
+	if progress.accepted == nil && !domain.IsGatewayTimeout(err) {
+		c.releaseFrequencyCap(ctx, functionName, *msg)
+	}
+	if err != nil {
+		// Permanent errors such as an invalid recipient fail the message straight away
+		if !domain.IsRetryableGatewayError(err) {
+			c.handlePermanentFailure(ctx, functionName, *msg, err.Error())
+			result.outcome = dispatchOutcomePermanent
+			return result
+		}
+		c.handleFailedMessage(ctx, functionName, *msg, err.Error())
+		result.outcome = dispatchOutcomeFailed
+		return result
+	}
+
+	// The gateway answered but did not accept the message, retry it like a failed send
+	if response.Status != domain.MessageStatusSent {
+		c.handleFailedMessage(ctx, functionName, *msg, errGatewayRejected)
+		result.outcome = dispatchOutcomeFailed
+		return result
+	}
+
+	// Update message status based on response
+	c.updateMessageStatus(ctx, functionName, *msg, &response)
+	result.outcome = dispatchOutcomeSent
+	return result
+}
+
+func (c *MessagingSvc) recordAttempt(ctx context.Context, functionName string, msgID int64, attemptedAt time.Time, response *domain.MessageDomain, sendErr error) {
+	attempt := domain.MessageAttempt{
+		MessageID:   msgID,
+		Status:      domain.MessageStatusSent,
+		Duration:    time.Since(attemptedAt),
+		AttemptedAt: attemptedAt,
+	}
+
+	switch {
+	case sendErr != nil:
+		errMsg := sendErr.Error()
+		attempt.Status = domain.MessageStatusFailed
+		attempt.Error = &errMsg
+	case response.Status != domain.MessageStatusSent:
+		errMsg := errGatewayRejected
+		attempt.Status = domain.MessageStatusFailed
+		attempt.Error = &errMsg
+	default:
+		// An accepted send can still carry a note for review, e.g. an unreadable provider answer
+		attempt.Error = response.LastError
+	}
+	attempt.ProviderMessageID = response.MessageID
+
+	if err := c.MessagingPersistence.CreateMessageAttempt(ctx, &attempt); err != nil {
+		logger.Error(functionName, "failed_to_record_message_attempt", msgID, err)
 	}
 }
 
@@ -73,22 +362,54 @@ func (c *MessagingSvc) handleAlreadySentMessage(ctx context.Context, functionNam
 		}
 	}
 
-	if err := c.MessagingPersistence.UpdateMessage(ctx, &updateParams); err != nil {
-		logger.Error(functionName, "failed_to_update_message_status", err)
+	c.recordOutcome(ctx, functionName, &updateParams, "failed_to_update_message_status")
+}
+
+// errGatewayRejected is recorded when the messaging service answers without accepting the message
+const errGatewayRejected = "messaging service did not accept the message"
+
+// handleFailedMessage marks the message failed and schedules its next attempt according to the retry policy,
+// once the attempts are used up the message is dead-lettered instead
+func (c *MessagingSvc) handleFailedMessage(ctx context.Context, functionName string, msg domain.MessageDomain, reason string) {
+	failures := msg.RetryCount + 1
+	updateParams := domain.MessageDomain{
+		ID:            msg.ID,
+		Status:        domain.MessageStatusFailed,
+		NextAttemptAt: c.Dispatch.Retry.NextAttemptAt(failures, time.Now()),
+		LastError:     &reason,
+	}
+	if updateParams.NextAttemptAt != nil {
+		logger.Error(functionName, "failed_to_send_message", msg.ID, "failures", failures, "next_attempt_at", updateParams.NextAttemptAt.Format(time.RFC3339))
+	} else {
+		updateParams.Status = domain.MessageStatusDead
+		logger.Error(functionName, "message_dead_lettered", msg.ID, "failures", failures, "last_error", reason)
+	}
+
+	c.recordOutcome(ctx, functionName, &updateParams, "failed_to_update_failed_message_status")
+}
+
+// handlePermanentFailure dead-letters a message the gateway will never accept without using up its retries
+func (c *MessagingSvc) handlePermanentFailure(ctx context.Context, functionName string, msg domain.MessageDomain, reason string) {
+	logger.Error(functionName, "message_permanently_failed", msg.ID, "last_error", reason)
+
+	updateParams := domain.MessageDomain{
+		ID:        msg.ID,
+		Status:    domain.MessageStatusDead,
+		LastError: &reason,
 	}
+
+	c.recordOutcome(ctx, functionName, &updateParams, "failed_to_update_permanently_failed_message_status")
 }
 
-func (c *MessagingSvc) handleFailedMessage(ctx context.Context, functionName string, msg domain.MessageDomain) {
-	logger.Error(functionName, "failed_to_send_message", msg.ID)
+func (c *MessagingSvc) handleExpiredMessage(ctx context.Context, functionName string, msg domain.MessageDomain) {
+	logger.Info(functionName, "message_expired", msg.ID)
 
 	updateParams := domain.MessageDomain{
 		ID:     msg.ID,
-		Status: domain.MessageStatusFailed,
+		Status: domain.MessageStatusExpired,
 	}
 
-	if err := c.MessagingPersistence.UpdateMessage(ctx, &updateParams); err != nil {
-		logger.Error(functionName, "failed_to_update_failed_message_status", err)
-	}
+	c.recordOutcome(ctx, functionName, &updateParams, "failed_to_update_expired_message_status")
 }
 
 func (c *MessagingSvc) updateMessageStatus(ctx context.Context, functionName string, msg domain.MessageDomain, response *domain.MessageDomain) {
@@ -102,21 +423,37 @@ func (c *MessagingSvc) updateMessageStatus(ctx context.Context, functionName str
 	if response.Status == domain.MessageStatusSent {
 		updateParams.MessageID = response.MessageID
 		updateParams.SentAt = response.SentAt
+		// Set when the provider accepted the message but something about the answer needs a review
+		updateParams.LastError = response.LastError
 
 		if err := c.cacheSentMessage(ctx, msg.ID, response); err != nil {
 			logger.Error(functionName, "failed_to_cache_message_sent", err)
 		}
 	}
 
-	if err := c.MessagingPersistence.UpdateMessage(ctx, &updateParams); err != nil {
-		logger.Error(functionName, "failed_to_update_message_status", err)
+	c.recordOutcome(ctx, functionName, &updateParams, "failed_to_update_message_status")
+}
+
+// recordOutcome writes the outcome of a claimed message. The write only lands while this instance still holds
+// the claim, once the lease ran out the message may already be with another instance and the stale outcome
+// is dropped rather than overwriting the newer one.
+func (c *MessagingSvc) recordOutcome(ctx context.Context, functionName string, updateParams *domain.MessageDomain, failureEvent string) {
+	updated, err := c.MessagingPersistence.UpdateMessage(ctx, updateParams, c.Dispatch.InstanceID)
+	if err != nil {
+		logger.Error(functionName, failureEvent, err)
+		return
+	}
+	if !updated {
+		logger.Error(functionName, "message_lease_lost", updateParams.ID, "status", updateParams.Status)
 	}
 }
 
 func (c *MessagingSvc) cacheSentMessage(ctx context.Context, msgID int64, response *domain.MessageDomain) error {
 	data := map[string]string{
-		"response_message_id": *response.MessageID,
-		"message_sent_at":     response.SentAt.Format(time.DateTime),
+		"message_sent_at": response.SentAt.Format(time.DateTime),
+	}
+	if response.MessageID != nil {
+		data["response_message_id"] = *response.MessageID
 	}
 
 	jsonData, err := json.Marshal(data)
@@ -127,27 +464,269 @@ func (c *MessagingSvc) cacheSentMessage(ctx context.Context, msgID int64, respon
 	return c.Redis.Set(ctx, "messageSent_"+cast.ToString(msgID), jsonData, 24*time.Hour)
 }
 
-func (c *MessagingSvc) ListSentMessages(ctx context.Context, limit, offset int32) ([]*domain.MessageDomain, int64, bool, error) {
+func (c *MessagingSvc) ListSentMessages(ctx context.Context, page domain.PageRequest) (*domain.MessagePage, error) {
 	const functionName = "messaging.MessagingSvc.ListSentMessages"
 
-	// Get sent messages from persistence layer
-	messages, err := c.MessagingPersistence.GetSentMessages(ctx, limit, offset)
+	// Get sent messages from persistence layer, continuing after the cursor when one is given
+	var (
+		messages []*domain.MessageDomain
+		err      error
+	)
+	if page.Cursor != nil {
+		messages, err = c.MessagingPersistence.GetSentMessagesAfterCursor(ctx, page.Cursor, fetchLimit(page.Limit))
+	} else {
+		messages, err = c.MessagingPersistence.GetSentMessages(ctx, fetchLimit(page.Limit), page.Offset)
+	}
 	if err != nil {
 		logger.Error(functionName, "failed_to_get_sent_messages", err)
-		return nil, 0, false, err
+		return nil, err
 	}
 
+	result := buildMessagePage(messages, page.Limit, func(msg *domain.MessageDomain) *domain.MessageCursor {
+		return &domain.MessageCursor{SortTime: msg.SentAt, ID: msg.ID}
+	})
+
 	// Get total count for pagination metadata
-	totalCount, err := c.MessagingPersistence.GetSentMessagesCount(ctx)
+	if page.IncludeTotal {
+		totalCount, err := c.MessagingPersistence.GetSentMessagesCount(ctx)
+		if err != nil {
+			logger.Error(functionName, "failed_to_get_sent_messages_count", err)
+			return nil, err
+		}
+		result.Total = &totalCount
+	}
+
+	logger.Info(functionName, "sent_messages_retrieved_successfully", "count", len(result.Messages), "hasMore", result.HasMore)
+
+	return result, nil
+}
+
+func (c *MessagingSvc) ListExpiredMessages(ctx context.Context, page domain.PageRequest) (*domain.MessagePage, error) {
+	const functionName = "messaging.MessagingSvc.ListExpiredMessages"
+
+	// Get expired messages from persistence layer, continuing after the cursor when one is given
+	var (
+		messages []*domain.MessageDomain
+		err      error
+	)
+	if page.Cursor != nil {
+		messages, err = c.MessagingPersistence.GetExpiredMessagesAfterCursor(ctx, page.Cursor, fetchLimit(page.Limit))
+	} else {
+		messages, err = c.MessagingPersistence.GetExpiredMessages(ctx, fetchLimit(page.Limit), page.Offset)
+	}
+	if err != nil {
+		logger.Error(functionName, "failed_to_get_expired_messages", err)
+		return nil, err
+	}
+
+	result := buildMessagePage(messages, page.Limit, func(msg *domain.MessageDomain) *domain.MessageCursor {
+		return &domain.MessageCursor{SortTime: msg.ExpiresAt, ID: msg.ID}
+	})
+
+	// Get total count for pagination metadata
+	if page.IncludeTotal {
+		totalCount, err := c.MessagingPersistence.GetExpiredMessagesCount(ctx)
+		if err != nil {
+			logger.Error(functionName, "failed_to_get_expired_messages_count", err)
+			return nil, err
+		}
+		result.Total = &totalCount
+	}
+
+	logger.Info(functionName, "expired_messages_retrieved_successfully", "count", len(result.Messages), "hasMore", result.HasMore)
+
+	return result, nil
+}
+
+// CancelMessage cancels a message that has not been dispatched yet, cancelling an already cancelled message
+// succeeds. It returns domain.ErrMessageNotFound for unknown ids and an error wrapping
+// domain.ErrMessageNotCancellable that names the status when the message already left the queue.
+func (c *MessagingSvc) CancelMessage(ctx context.Context, id int64) (*domain.MessageDomain, error) {
+	const functionName = "messaging.MessagingSvc.CancelMessage"
+
+	cancelled, err := c.MessagingPersistence.CancelMessage(ctx, id)
+	if err != nil {
+		logger.Error(functionName, "failed_to_cancel_message", err)
+		return nil, err
+	}
+
+	msg, err := c.MessagingPersistence.GetMessageByID(ctx, id)
+	if err != nil {
+		logger.Error(functionName, "failed_to_get_message", id, err)
+		return nil, err
+	}
+
+	if !cancelled {
+		// Cancelling twice is not an error, the message ends up cancelled either way
+		if msg.Status == domain.MessageStatusCancelled {
+			logger.Info(functionName, "message_already_cancelled", id)
+			return msg, nil
+		}
+		logger.Info(functionName, "message_not_cancellable", id, msg.Status)
+		return msg, domain.NotCancellableError(msg.Status)
+	}
+
+	logger.Info(functionName, "message_cancelled_successfully", id)
+
+	return msg, nil
+}
+
+// GetMessage returns a single message together with every send attempt made for it
+func (c *MessagingSvc) GetMessage(ctx context.Context, id int64) (*domain.MessageDomain, []*domain.MessageAttempt, error) {
+	const functionName = "messaging.MessagingSvc.GetMessage"
+
+	msg, err := c.MessagingPersistence.GetMessageByID(ctx, id)
+	if err != nil {
+		logger.Error(functionName, "failed_to_get_message", id, err)
+		return nil, nil, err
+	}
+
+	attempts, err := c.MessagingPersistence.GetMessageAttempts(ctx, id)
+	if err != nil {
+		logger.Error(functionName, "failed_to_get_message_attempts", id, err)
+		return nil, nil, err
+	}
+
+	logger.Info(functionName, "message_retrieved_successfully", id, "attempts", len(attempts))
+
+	return msg, attempts, nil
+}
+
+func (c *MessagingSvc) SearchMessages(ctx context.Context, filter *domain.MessageFilter, page domain.PageRequest) (*domain.MessagePage, error) {
+	const functionName = "messaging.MessagingSvc.SearchMessages"
+
+	// Get matching messages from persistence layer
+	messages, err := c.MessagingPersistence.SearchMessages(ctx, filter, fetchLimit(page.Limit), page.Offset, page.Cursor)
+	if err != nil {
+		logger.Error(functionName, "failed_to_search_messages", err)
+		return nil, err
+	}
+
+	result := buildMessagePage(messages, page.Limit, func(msg *domain.MessageDomain) *domain.MessageCursor {
+		return &domain.MessageCursor{ID: msg.ID}
+	})
+
+	// Get total count for pagination metadata
+	if page.IncludeTotal {
+		totalCount, err := c.MessagingPersistence.SearchMessagesCount(ctx, filter)
+		if err != nil {
+			logger.Error(functionName, "failed_to_get_search_messages_count", err)
+			return nil, err
+		}
+		result.Total = &totalCount
+	}
+
+	logger.Info(functionName, "messages_searched_successfully", "count", len(result.Messages), "hasMore", result.HasMore)
+
+	return result, nil
+}
+
+// ExportMessages streams every message matching the filter to fn, oldest first
+func (c *MessagingSvc) ExportMessages(ctx context.Context, filter *domain.MessageFilter, fn func(*domain.MessageDomain) error) error {
+	const functionName = "messaging.MessagingSvc.ExportMessages"
+
+	var count int
+	err := c.MessagingPersistence.StreamMessages(ctx, filter, func(msg *domain.MessageDomain) error {
+		count++
+		return fn(msg)
+	})
+	if err != nil {
+		logger.Error(functionName, "failed_to_export_messages", err, "exported", count)
+		return err
+	}
+
+	logger.Info(functionName, "messages_exported_successfully", "count", count)
+
+	return nil
+}
+
+// ListDeadMessages lists messages that used up their retry attempts, newest first
+func (c *MessagingSvc) ListDeadMessages(ctx context.Context, page domain.PageRequest) (*domain.MessagePage, error) {
+	const functionName = "messaging.MessagingSvc.ListDeadMessages"
+
+	status := domain.MessageStatusDead
+	filter := &domain.MessageFilter{Status: &status}
+	messages, err := c.MessagingPersistence.SearchMessages(ctx, filter, fetchLimit(page.Limit), page.Offset, page.Cursor)
+	if err != nil {
+		logger.Error(functionName, "failed_to_get_dead_messages", err)
+		return nil, err
+	}
+
+	result := buildMessagePage(messages, page.Limit, func(msg *domain.MessageDomain) *domain.MessageCursor {
+		return &domain.MessageCursor{ID: msg.ID}
+	})
+
+	// Get total count for pagination metadata
+	if page.IncludeTotal {
+		totalCount, err := c.MessagingPersistence.SearchMessagesCount(ctx, filter)
+		if err != nil {
+			logger.Error(functionName, "failed_to_get_dead_messages_count", err)
+			return nil, err
+		}
+		result.Total = &totalCount
+	}
+
+	logger.Info(functionName, "dead_messages_retrieved_successfully", "count", len(result.Messages), "hasMore", result.HasMore)
+
+	return result, nil
+}
+
+// RequeueMessage sends a dead-lettered message back to pending with a fresh retry count
+func (c *MessagingSvc) RequeueMessage(ctx context.Context, id int64) (*domain.MessageDomain, error) {
+	const functionName = "messaging.MessagingSvc.RequeueMessage"
+
+	requeued, err := c.MessagingPersistence.RequeueMessage(ctx, id)
+	if err != nil {
+		logger.Error(functionName, "failed_to_requeue_message", err)
+		return nil, err
+	}
+
+	msg, err := c.MessagingPersistence.GetMessageByID(ctx, id)
+	if err != nil {
+		logger.Error(functionName, "failed_to_get_message", id, err)
+		return nil, err
+	}
+
+	if !requeued {
+		logger.Info(functionName, "message_not_requeueable", id, msg.Status)
+		return msg, domain.ErrMessageNotRequeueable
+	}
+
+	logger.Info(functionName, "message_requeued_successfully", id)
+
+	return msg, nil
+}
+
+// RequeueMessages sends every dead-lettered message matching the filter back to pending and returns how many were requeued
+func (c *MessagingSvc) RequeueMessages(ctx context.Context, filter *domain.MessageFilter) (int64, error) {
+	const functionName = "messaging.MessagingSvc.RequeueMessages"
+
+	count, err := c.MessagingPersistence.RequeueDeadMessages(ctx, filter)
 	if err != nil {
-		logger.Error(functionName, "failed_to_get_sent_messages_count", err)
-		return nil, 0, false, err
+		logger.Error(functionName, "failed_to_requeue_messages", err)
+		return 0, err
 	}
 
-	// Calculate hasMore
-	hasMore := (offset + limit) < int32(totalCount)
+	logger.Info(functionName, "messages_requeued_successfully", "count", count)
+
+	return count, nil
+}
 
-	logger.Info(functionName, "sent_messages_retrieved_successfully", "count", len(messages), "total", totalCount, "hasMore", hasMore)
+// fetchLimit asks for one row more than the page size so the next page can be detected without counting
+func fetchLimit(limit int32) int32 {
+	if limit <= 0 {
+		return limit
+	}
+	return limit + 1
+}
 
-	return messages, totalCount, hasMore, nil
+// buildMessagePage trims the extra row requested by fetchLimit and derives the cursor of the next page
+func buildMessagePage(messages []*domain.MessageDomain, limit int32, cursorOf func(*domain.MessageDomain) *domain.MessageCursor) *domain.MessagePage {
+	page := &domain.MessagePage{Messages: messages}
+	if limit > 0 && len(messages) > int(limit) {
+		page.Messages = messages[:limit]
+		page.HasMore = true
+		page.NextCursor = cursorOf(page.Messages[len(page.Messages)-1])
+	}
+	return page
 }
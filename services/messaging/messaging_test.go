@@ -0,0 +1,69 @@
+package messaging
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+	"github.com/smitendu1997/auto-message-dispatcher/persistence/message"
+)
+
+// fakeGateway accepts every message and counts the sends
+type fakeGateway struct {
+	sends int
+}
+
+func (g *fakeGateway) SendMessage(ctx context.Context, msg *domain.MessageDomain) (domain.MessageDomain, error) {
+	g.sends++
+	sentAt := time.Now()
+	return domain.MessageDomain{ID: msg.ID, Status: domain.MessageStatusSent, SentAt: &sentAt}, nil
+}
+
+// fakeOutcomePersistence accepts the attempt and status writes made after a send. Other calls are not
+// implemented and panic through the embedded nil interface.
+type fakeOutcomePersistence struct {
+	message.MessagePersistence
+}
+
+func (p *fakeOutcomePersistence) CreateMessageAttempt(ctx context.Context, attempt *domain.MessageAttempt) error {
+	return nil
+}
+
+func (p *fakeOutcomePersistence) UpdateMessage(ctx context.Context, msg *domain.MessageDomain, owner string) (bool, error) {
+	return true, nil
+}
+
+func TestProcessMessageWithinTheLease(t *testing.T) {
+	tests := []struct {
+		name        string
+		leaseLeft   time.Duration
+		wantOutcome dispatchOutcome
+		wantSends   int
+	}{
+		{name: "lease outlasts the send timeout", leaseLeft: 5 * time.Second, wantOutcome: dispatchOutcomeSent, wantSends: 1},
+		{name: "lease ends before the send timeout", leaseLeft: 500 * time.Millisecond, wantOutcome: dispatchOutcomeSkipped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := &fakeGateway{}
+			svc := &MessagingSvc{
+				MessagingPersistence: &fakeOutcomePersistence{},
+				Gateways:             gateway,
+				Redis:                newTestRedis(t),
+				Dispatch:             DispatchConfig{SendTimeout: time.Second},
+				limiter:              newLocalRateLimiter(0),
+			}
+			msg := &domain.MessageDomain{ID: 1, RecipientPhone: "+905551111111", Content: "hello"}
+
+			result := svc.processMessage(context.Background(), "test", msg, time.Now().Add(tt.leaseLeft), &dispatchProgress{})
+			if result.outcome != tt.wantOutcome {
+				t.Fatalf("outcome = %s, want %s", result.outcome, tt.wantOutcome)
+			}
+			if gateway.sends != tt.wantSends {
+				t.Fatalf("gateway called %d times, want %d", gateway.sends, tt.wantSends)
+			}
+		})
+	}
+}
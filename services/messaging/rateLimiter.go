@@ -0,0 +1,312 @@
+package messaging
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+	"github.com/smitendu1997/auto-message-dispatcher/logger"
+	"github.com/smitendu1997/auto-message-dispatcher/utils/redis"
+	"github.com/spf13/cast"
+)
+
+// RateLimiter paces calls to the messaging gateway
+type RateLimiter interface {
+	// Wait blocks until the next send is allowed or the context is done
+	Wait(ctx context.Context) error
+	// SetRate changes the limit in messages per second, 0 disables limiting
+	SetRate(rate float64)
+	Stats() domain.RateLimitStats
+}
+
+const (
+	rateLimiterBackendRedis = "redis"
+	rateLimiterBackendLocal = "local"
+
+	// gatewayRateLimitKey is the token bucket shared by every replica
+	gatewayRateLimitKey = "messaging:ratelimit:gateway"
+	// rateLimitFallbackLogInterval keeps a Redis outage from logging on every send
+	rateLimitFallbackLogInterval = time.Minute
+	// rateLimiterMinBackoff and rateLimiterMaxBackoff bound how long Redis is left alone after a
+	// failed call, the backoff doubles with every failure in a row
+	rateLimiterMinBackoff = time.Second
+	rateLimiterMaxBackoff = 30 * time.Second
+)
+
+// takeTokenScript refills the bucket at rate ARGV[1] up to burst ARGV[2] for the time since it was last
+// used, then takes a token if one is available. It returns 0 when a token was taken, otherwise how many
+// milliseconds until one will be. Redis time is used so replicas with drifting clocks share the bucket
+// fairly.
+const takeTokenScript = `
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+else
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+return wait`
+
+// redisRateLimiter is a token bucket kept in Redis so the limit holds across every replica. Only the
+// tokens live in Redis, the rate comes from the dispatch settings every replica syncs from the poller's
+// settings store. After Redis fails it is left alone for a backoff and sends are paced by an in-process
+// limiter running at this replica's share of the limit.
+type redisRateLimiter struct {
+	redis    *redis.RedisClient
+	key      string
+	fallback *localRateLimiter
+	// fallbackRate caps this replica while Redis is down, 0 falls back to an even share of the rate
+	fallbackRate float64
+	// replicas is how many replicas the rate is shared by when no fallbackRate is set
+	replicas int
+
+	mu              sync.RWMutex
+	rate            float64
+	burst           float64
+	backoff         time.Duration
+	retryAt         time.Time
+	lastFallbackLog time.Time
+
+	stats rateLimitStats
+}
+
+// newRedisRateLimiter returns a limiter for rate messages per second. A burst of 0 allows one second's
+// worth, any other burst is used as given and must be at least 1. Nothing is written to Redis until the
+// first send.
+func newRedisRateLimiter(client *redis.RedisClient, rate, burst, fallbackRate float64, replicas int) *redisRateLimiter {
+	l := &redisRateLimiter{
+		redis:        client,
+		key:          gatewayRateLimitKey,
+		fallback:     newLocalRateLimiter(0),
+		fallbackRate: fallbackRate,
+		replicas:     max(replicas, 1),
+		burst:        burst,
+	}
+	l.SetRate(rate)
+	return l
+}
+
+func (l *redisRateLimiter) SetRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.fallback.SetRate(l.fallbackLimit())
+}
+
+// fallbackLimit is this replica's rate while Redis is down, never above the shared rate. Callers hold mu.
+func (l *redisRateLimiter) fallbackLimit() float64 {
+	if l.fallbackRate > 0 {
+		return min(l.fallbackRate, l.rate)
+	}
+	return l.rate / float64(l.replicas)
+}
+
+// limits returns the rate and burst to apply
+func (l *redisRateLimiter) limits() (float64, float64) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	burst := l.burst
+	if burst == 0 {
+		burst = max(math.Ceil(l.rate), 1)
+	}
+	return l.rate, burst
+}
+
+func (l *redisRateLimiter) Wait(ctx context.Context) error {
+	const functionName = "messaging.redisRateLimiter.Wait"
+
+	rate, burst := l.limits()
+	if rate <= 0 {
+		return nil
+	}
+
+	var waited time.Duration
+	for {
+		if l.backingOff() {
+			return l.waitFallback(ctx, waited)
+		}
+		res, err := l.redis.Eval(ctx, takeTokenScript, []string{l.key}, rate, burst)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			l.backOff(functionName, err)
+			return l.waitFallback(ctx, waited)
+		}
+
+		l.recovered()
+		wait := time.Duration(cast.ToInt64(res)) * time.Millisecond
+		if wait <= 0 {
+			l.stats.record(waited, false)
+			return nil
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+		waited += wait
+	}
+}
+
+// recovered closes the backoff once Redis answers again
+func (l *redisRateLimiter) recovered() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.backoff = 0
+	l.retryAt = time.Time{}
+}
+
+// backingOff reports whether Redis failed recently enough that it should not be called yet
+func (l *redisRateLimiter) backingOff() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return time.Now().Before(l.retryAt)
+}
+
+// backOff leaves Redis alone for a while after a failed call, logging at most once a minute
+func (l *redisRateLimiter) backOff(functionName string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.backoff = min(max(l.backoff*2, rateLimiterMinBackoff), rateLimiterMaxBackoff)
+	l.retryAt = time.Now().Add(l.backoff)
+	if time.Since(l.lastFallbackLog) < rateLimitFallbackLogInterval {
+		return
+	}
+	l.lastFallbackLog = time.Now()
+	logger.Error(functionName, "rate_limiter_falling_back_to_local", err, "fallback_rate", l.fallbackLimit(), "retry_in", l.backoff.String())
+}
+
+// waitFallback paces the send in process while Redis is unavailable
+func (l *redisRateLimiter) waitFallback(ctx context.Context, waited time.Duration) error {
+	fallbackWait, err := l.fallback.wait(ctx)
+	if err == nil {
+		l.stats.record(waited+fallbackWait, true)
+	}
+	return err
+}
+
+func (l *redisRateLimiter) Stats() domain.RateLimitStats {
+	rate, burst := l.limits()
+	stats := l.stats.snapshot()
+	stats.Backend = rateLimiterBackendRedis
+	stats.Rate = rate
+	stats.Burst = burst
+	return stats
+}
+
+// localRateLimiter spaces sends evenly so that no more than rate messages go out per second from this
+// instance. A rate of 0 disables limiting.
+type localRateLimiter struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time
+
+	stats rateLimitStats
+}
+
+func newLocalRateLimiter(rate float64) *localRateLimiter {
+	return &localRateLimiter{rate: rate}
+}
+
+// SetRate changes the limit, it applies to the next send
+func (l *localRateLimiter) SetRate(rate float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.next = time.Time{}
+}
+
+func (l *localRateLimiter) Wait(ctx context.Context) error {
+	waited, err := l.wait(ctx)
+	if err == nil {
+		l.stats.record(waited, false)
+	}
+	return err
+}
+
+// wait blocks until the next send is allowed and returns how long that took
+func (l *localRateLimiter) wait(ctx context.Context) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return 0, nil
+	}
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(float64(time.Second) / l.rate))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return 0, nil
+	}
+	return wait, sleepContext(ctx, wait)
+}
+
+func (l *localRateLimiter) Stats() domain.RateLimitStats {
+	l.mu.Lock()
+	rate := l.rate
+	l.mu.Unlock()
+	stats := l.stats.snapshot()
+	stats.Backend = rateLimiterBackendLocal
+	stats.Rate = rate
+	stats.Burst = 1
+	return stats
+}
+
+// sleepContext waits for d or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitStats counts how often sends were allowed and how long throttled sends waited
+type rateLimitStats struct {
+	mu    sync.Mutex
+	stats domain.RateLimitStats
+}
+
+func (s *rateLimitStats) record(waited time.Duration, fellBack bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Allowed++
+	if fellBack {
+		s.stats.Fallbacks++
+	}
+	if waited > 0 {
+		s.stats.Throttled++
+		s.stats.ThrottledWait += waited
+		s.stats.MaxThrottledWait = max(s.stats.MaxThrottledWait, waited)
+	}
+}
+
+func (s *rateLimitStats) snapshot() domain.RateLimitStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
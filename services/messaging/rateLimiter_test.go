@@ -0,0 +1,170 @@
+package messaging
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/smitendu1997/auto-message-dispatcher/utils/redis"
+	"github.com/spf13/cast"
+)
+
+// newTestRedis connects to the Redis at TEST_REDIS_ADDR when it is set, so the scripts can be checked
+// against a real server, and to an in-memory miniredis otherwise
+func newTestRedis(t *testing.T) *redis.RedisClient {
+	t.Helper()
+
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		server := miniredis.RunT(t)
+		addr = server.Addr()
+	}
+	client, err := redis.NewRedisClient(&redis.RedisConfig{Addresses: []string{addr}})
+	if err != nil {
+		t.Fatalf("failed to connect to redis at %s: %v", addr, err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// testKey returns a key no other test uses, and removes it once the test is done
+func testKey(t *testing.T, client *redis.RedisClient, prefix string) string {
+	t.Helper()
+	key := prefix + "test:" + t.Name()
+	client.Del(context.Background(), key)
+	t.Cleanup(func() { client.Del(context.Background(), key) })
+	return key
+}
+
+// takeToken runs takeTokenScript once and returns the wait in milliseconds
+func takeToken(t *testing.T, client *redis.RedisClient, key string, rate, burst float64) int64 {
+	t.Helper()
+	res, err := client.Eval(context.Background(), takeTokenScript, []string{key}, rate, burst)
+	if err != nil {
+		t.Fatalf("takeTokenScript failed: %v", err)
+	}
+	return cast.ToInt64(res)
+}
+
+func TestTakeTokenScript(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		burst float64
+		// free is how many tokens are taken back to back without waiting
+		free int
+		// minWait and maxWait bound the wait in milliseconds for the token after those
+		minWait, maxWait int64
+	}{
+		{name: "burst of one", rate: 1, burst: 1, free: 1, minWait: 900, maxWait: 1000},
+		{name: "burst above the rate", rate: 2, burst: 5, free: 5, minWait: 400, maxWait: 500},
+		{name: "fractional rate", rate: 0.5, burst: 1, free: 1, minWait: 1900, maxWait: 2000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestRedis(t)
+			key := testKey(t, client, gatewayRateLimitKey)
+
+			for i := range tt.free {
+				if wait := takeToken(t, client, key, tt.rate, tt.burst); wait != 0 {
+					t.Fatalf("token %d waited %dms, want none within the burst", i+1, wait)
+				}
+			}
+			wait := takeToken(t, client, key, tt.rate, tt.burst)
+			if wait < tt.minWait || wait > tt.maxWait {
+				t.Fatalf("token after the burst waited %dms, want between %d and %d", wait, tt.minWait, tt.maxWait)
+			}
+		})
+	}
+}
+
+func TestTakeTokenScriptUsesTheCallersRate(t *testing.T) {
+	client := newTestRedis(t)
+	key := testKey(t, client, gatewayRateLimitKey)
+
+	// The rate is not kept in the bucket, so a replica started with another configured rate cannot
+	// change the rate the others apply
+	takeToken(t, client, key, 50, 1)
+	if wait := takeToken(t, client, key, 1, 1); wait < 900 || wait > 1000 {
+		t.Fatalf("token at rate 1 waited %dms, want about a second", wait)
+	}
+}
+
+func TestRedisRateLimiterLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		rate      float64
+		burst     float64
+		wantBurst float64
+	}{
+		{name: "no burst allows a second's worth", rate: 2.5, wantBurst: 3},
+		{name: "no burst allows at least one", rate: 0.2, wantBurst: 1},
+		{name: "a burst below the rate is kept", rate: 10, burst: 2, wantBurst: 2},
+		{name: "a burst above the rate is kept", rate: 10, burst: 40, wantBurst: 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRedisRateLimiter(nil, tt.rate, tt.burst, 0, 1)
+			if _, burst := l.limits(); burst != tt.wantBurst {
+				t.Fatalf("burst = %g, want %g", burst, tt.wantBurst)
+			}
+		})
+	}
+}
+
+func TestRedisRateLimiterFallbackRate(t *testing.T) {
+	tests := []struct {
+		name         string
+		rate         float64
+		fallbackRate float64
+		replicas     int
+		want         float64
+	}{
+		{name: "the rate is shared by the replicas", rate: 100, replicas: 4, want: 25},
+		{name: "a single replica gets the whole rate", rate: 100, want: 100},
+		{name: "an explicit fallback is used", rate: 100, fallbackRate: 10, replicas: 4, want: 10},
+		{name: "an explicit fallback never exceeds the rate", rate: 5, fallbackRate: 10, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRedisRateLimiter(nil, tt.rate, 0, tt.fallbackRate, tt.replicas)
+			if rate := l.fallback.Stats().Rate; rate != tt.want {
+				t.Fatalf("fallback rate = %g, want %g", rate, tt.want)
+			}
+			// A rate changed at runtime moves the default share with it
+			l.SetRate(tt.rate * 2)
+			if tt.fallbackRate == 0 {
+				if rate := l.fallback.Stats().Rate; rate != tt.want*2 {
+					t.Fatalf("fallback rate after doubling the rate = %g, want %g", rate, tt.want*2)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisRateLimiterWithoutRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client, err := redis.NewRedisClient(&redis.RedisConfig{Addresses: []string{server.Addr()}, MaxRetries: -1})
+	if err != nil {
+		t.Fatalf("failed to connect to miniredis: %v", err)
+	}
+	defer client.Close()
+	server.Close()
+
+	l := newRedisRateLimiter(client, 100, 0, 0, 2)
+	for i := range 2 {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait %d error = %v, want the send paced locally", i+1, err)
+		}
+		if !l.backingOff() {
+			t.Fatalf("Wait %d left Redis to be called again straight away", i+1)
+		}
+	}
+	if fallbacks := l.Stats().Fallbacks; fallbacks != 2 {
+		t.Fatalf("fallbacks = %d, want 2", fallbacks)
+	}
+}
@@ -0,0 +1,51 @@
+package messaging
+
+import (
+	"fmt"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+	"github.com/smitendu1997/auto-message-dispatcher/logger"
+)
+
+// DispatchSettings returns the batch size, worker count and rate limit currently in effect
+func (c *MessagingSvc) DispatchSettings() domain.DispatchSettings {
+	c.settingsMu.RLock()
+	defer c.settingsMu.RUnlock()
+	return c.settings
+}
+
+// UpdateDispatchSettings replaces the dispatch settings. A poll already running keeps the batch size
+// and worker count it started with, the rate limit applies from the next send. Non-positive batch
+// sizes and worker counts keep their current value and a negative rate limit is treated as unlimited.
+// Settings under which the rate limit or the workers cannot send a batch within its lease are rejected
+// with an error wrapping domain.ErrBatchOutlastsLease. It returns the settings now in effect.
+func (c *MessagingSvc) UpdateDispatchSettings(settings domain.DispatchSettings) (domain.DispatchSettings, error) {
+	const functionName = "messaging.MessagingSvc.UpdateDispatchSettings"
+
+	c.settingsMu.Lock()
+	defer c.settingsMu.Unlock()
+
+	if settings.BatchSize <= 0 {
+		settings.BatchSize = c.settings.BatchSize
+	}
+	if settings.Workers <= 0 {
+		settings.Workers = c.settings.Workers
+	}
+	settings.RateLimit = max(settings.RateLimit, 0)
+
+	if limit := settings.ClaimLimit(c.Dispatch.LeaseDuration, c.Dispatch.SendTimeout); limit < settings.BatchSize {
+		return c.settings, fmt.Errorf("%w: at most %d messages can be sent within the %s lease when a send takes up to %s",
+			domain.ErrBatchOutlastsLease, limit, c.Dispatch.LeaseDuration, c.Dispatch.SendTimeout)
+	}
+
+	c.settings = settings
+	c.limiter.SetRate(settings.RateLimit)
+	logger.Info(functionName, "dispatch_settings_updated", "batch_size", settings.BatchSize,
+		"workers", settings.Workers, "rate_limit", settings.RateLimit)
+	return settings, nil
+}
+
+// RateLimitStats returns the outbound rate limit and how often sends had to wait for it
+func (c *MessagingSvc) RateLimitStats() domain.RateLimitStats {
+	return c.limiter.Stats()
+}
@@ -0,0 +1,48 @@
+package messaging
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smitendu1997/auto-message-dispatcher/domain"
+)
+
+func TestUpdateDispatchSettings(t *testing.T) {
+	current := domain.DispatchSettings{BatchSize: 100, Workers: 10}
+
+	tests := []struct {
+		name     string
+		settings domain.DispatchSettings
+		want     domain.DispatchSettings
+		wantErr  error
+	}{
+		{name: "unset values are kept", settings: domain.DispatchSettings{RateLimit: 5}, want: domain.DispatchSettings{BatchSize: 100, Workers: 10, RateLimit: 5}},
+		{name: "negative rate is unlimited", settings: domain.DispatchSettings{BatchSize: 20, RateLimit: -1}, want: domain.DispatchSettings{BatchSize: 20, Workers: 10}},
+		{name: "rate too low for the batch", settings: domain.DispatchSettings{RateLimit: 0.1}, wantErr: domain.ErrBatchOutlastsLease},
+		{name: "too few workers for the batch", settings: domain.DispatchSettings{Workers: 2}, wantErr: domain.ErrBatchOutlastsLease},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &MessagingSvc{
+				Dispatch: DispatchConfig{LeaseDuration: 6 * time.Minute, SendTimeout: 30 * time.Second},
+				settings: current,
+				limiter:  newLocalRateLimiter(0),
+			}
+			got, err := svc.UpdateDispatchSettings(tt.settings)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if svc.DispatchSettings() != current {
+					t.Fatalf("settings = %+v after a rejected update, want %+v", svc.DispatchSettings(), current)
+				}
+				return
+			}
+			if got != tt.want || svc.DispatchSettings() != tt.want {
+				t.Fatalf("settings = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"os"
 
 	"github.com/smitendu1997/auto-message-dispatcher/utils/db"
 	"github.com/smitendu1997/auto-message-dispatcher/utils/redis"
@@ -51,3 +54,23 @@ func InArraySlice(array []string, value string) bool {
 	}
 	return false
 }
+
+// DefaultInstanceID builds an identifier that is unique per running process, for replicas that are not
+// given one explicitly
+func DefaultInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "dispatcher"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// RandomToken returns 16 random hex characters, for telling apart processes that share an instance id
+func RandomToken() string {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		// crypto/rand does not fail on supported platforms, fall back to something still per process
+		return fmt.Sprintf("%x", os.Getpid())
+	}
+	return hex.EncodeToString(token)
+}
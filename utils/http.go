@@ -10,7 +10,7 @@ import (
 )
 
 func HttpCall(functionName string, ctx context.Context, method, url string, client http.Client, reqBody []byte, headers map[string]string) ([]byte, int, error) {
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(reqBody))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(reqBody))
 	if err != nil {
 		logger.Error(functionName, "http make request Error: ", err)
 		return nil, 0, err
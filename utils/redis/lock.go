@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	redisGo "github.com/redis/go-redis/v9"
+)
+
+// Lock is a lease on a Redis key held by a single owner. It expires after its TTL unless renewed,
+// so an owner that dies without releasing it frees it automatically.
+type Lock struct {
+	client *RedisClient
+	key    string
+	owner  string
+	ttl    time.Duration
+}
+
+// renewLockScript extends the lease only while the key still belongs to the caller
+const renewLockScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`
+
+// releaseLockScript deletes the key only while it still belongs to the caller
+const releaseLockScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+// NewLock returns a lock on key for owner, nothing is written to Redis until Acquire is called
+func (r *RedisClient) NewLock(key, owner string, ttl time.Duration) *Lock {
+	return &Lock{client: r, key: key, owner: owner, ttl: ttl}
+}
+
+// Owner returns the identity this lock is acquired as
+func (l *Lock) Owner() string {
+	return l.owner
+}
+
+// TTL returns how long the lock is held without renewal
+func (l *Lock) TTL() time.Duration {
+	return l.ttl
+}
+
+// Acquire takes the lock if it is free or extends it if the owner already holds it.
+// It reports whether the owner holds the lock afterwards.
+func (l *Lock) Acquire(ctx context.Context) (bool, error) {
+	acquired, err := l.client.SetNX(ctx, l.key, l.owner, l.ttl)
+	if err != nil || acquired {
+		return acquired, err
+	}
+	return l.Renew(ctx)
+}
+
+// Renew extends the lease, it reports false when the lock has expired or belongs to someone else
+func (l *Lock) Renew(ctx context.Context) (bool, error) {
+	res, err := l.client.Eval(ctx, renewLockScript, []string{l.key}, l.owner, l.ttl.Milliseconds())
+	if err != nil {
+		return false, err
+	}
+	renewed, _ := res.(int64)
+	return renewed == 1, nil
+}
+
+// Release frees the lock if the owner still holds it
+func (l *Lock) Release(ctx context.Context) error {
+	_, err := l.client.Eval(ctx, releaseLockScript, []string{l.key}, l.owner)
+	return err
+}
+
+// Holder returns the current owner of the lock, empty when nobody holds it
+func (l *Lock) Holder(ctx context.Context) (string, error) {
+	holder, err := l.client.Get(ctx, l.key)
+	if errors.Is(err, redisGo.Nil) {
+		return "", nil
+	}
+	return holder, err
+}